@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ntnn/mdextract/pkg/mdextract"
+)
+
+// findConfig loads the given configuration file or the one found from
+// the current directory upwards. Without a configuration file it
+// returns nil, unless required.
+func findConfig(path string, required bool) (*mdextract.Config, error) {
+	if path == "" {
+		found, err := mdextract.FindConfig(".")
+		if errors.Is(err, mdextract.ErrNoConfig) && !required {
+			return nil, nil //nolint:nilnil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		path = found
+
+		// relative paths keep the sources in error messages and line
+		// directives short
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, found); err == nil {
+				path = rel
+			}
+		}
+	}
+
+	return mdextract.LoadConfig(path)
+}
+
+// profileError adds the name of the profile to err.
+func profileError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+
+	return fmt.Errorf("profile %q: %w", name, err)
+}
+
+// parseFlags parses args with fs. Empty arguments are skipped, e.g. the
+// ones the GitHub Action passes for inputs that are not set.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 || args[0] != "" {
+			return nil
+		}
+
+		args = args[1:]
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ntnn/mdextract/pkg/mdextract"
+)
+
+func TestProfileOptions(t *testing.T) {
+	t.Parallel()
+
+	profile := mdextract.Profile{
+		Mode:     mdextract.ModeMulti,
+		Output:   "out.sh",
+		Format:   "json",
+		FileMode: "0755",
+		Check:    true,
+		Strict:   true,
+		Tags:     []string{"ci"},
+		Inputs:   []string{"README.md"},
+
+		Sections:        []string{"Install"},
+		KnownAttributes: []string{"os"},
+		ExcludePaths:    []string{"drafts"},
+	}
+
+	cases := map[string]struct {
+		args     []string
+		expected func(t *testing.T, opts *extractOptions, fs *flag.FlagSet)
+	}{
+		"profile": {
+			args: []string{},
+			expected: func(t *testing.T, opts *extractOptions, fs *flag.FlagSet) {
+				t.Helper()
+				assert.True(t, *opts.isMulti)
+				assert.Equal(t, "out.sh", *opts.output)
+				assert.Equal(t, "json", *opts.format)
+				assert.Equal(t, uint32(0o755), opts.multi.FileMode)
+				assert.True(t, *opts.check)
+				assert.True(t, opts.multi.Strict)
+				assert.Equal(t, []string{"ci"}, opts.multi.Tags)
+				assert.Equal(t, "(section=Install)", opts.multi.Section.String())
+				assert.Equal(t, []string{"os"}, opts.multi.KnownAttributes)
+				assert.Equal(t, []string{"drafts"}, *opts.excludePaths)
+				assert.Empty(t, fs.Args())
+			},
+		},
+		"flags override": {
+			args: []string{
+				"-multi=false", "-output=", "-format", "text", "-file-mode", "0600", "-check=false", "-strict=false",
+				"-tags", "docs", "-section", "Usage", "-section", "API", "-known-attributes", "arch",
+				"-exclude-path", "old", "guide.md",
+			},
+			expected: func(t *testing.T, opts *extractOptions, fs *flag.FlagSet) {
+				t.Helper()
+				assert.False(t, *opts.isMulti)
+				assert.Empty(t, *opts.output)
+				assert.Equal(t, "text", *opts.format)
+				assert.Equal(t, uint32(0o600), opts.multi.FileMode)
+				assert.False(t, *opts.check)
+				assert.False(t, opts.multi.Strict)
+				assert.Equal(t, []string{"docs"}, opts.multi.Tags)
+				assert.Equal(t, "(section=Usage || section=API)", opts.multi.Section.String())
+				assert.Equal(t, []string{"arch"}, opts.multi.KnownAttributes)
+				assert.Equal(t, []string{"old"}, *opts.excludePaths)
+				assert.Equal(t, []string{"guide.md"}, fs.Args())
+			},
+		},
+		"empty arguments": {
+			args: []string{"", "-check=false", "", ""},
+			expected: func(t *testing.T, opts *extractOptions, fs *flag.FlagSet) {
+				t.Helper()
+				assert.False(t, *opts.check)
+				assert.True(t, opts.multi.Strict)
+				assert.Empty(t, fs.Args())
+			},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			opts, fs, err := profileOptions(profile, cas.args)
+			require.NoError(t, err)
+			cas.expected(t, opts, fs)
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	t.Parallel()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	output := fs.String("output", "default", "")
+
+	require.NoError(t, parseFlags(fs, []string{"", "-output", "", "", "README.md", ""}))
+	assert.Empty(t, *output)
+	assert.Equal(t, []string{"README.md", ""}, fs.Args())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/ntnn/mdextract/pkg/mdextract"
+)
+
+// runEmbed updates code blocks with a source attribute in the given
+// markdown files with the contents of the referenced files.
+func runEmbed(args []string) error {
+	single := &mdextract.Single{}
+	fs := single.SelectFlagSet()
+	fs.Init("embed", fs.ErrorHandling())
+
+	fCheck := fs.Bool("check", false, "Compare the code blocks to the source files without writing, fails if they differ")
+	excludePaths := excludePathFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.PrintDefaults()
+		return errors.New("no input files specified")
+	}
+
+	inputs, err := findInputs(fs.Args(), *excludePaths)
+	if err != nil {
+		return err
+	}
+
+	diffs := []mdextract.Diff{}
+
+	for _, input := range inputs {
+		if input == "-" {
+			return errors.New("embed cannot read from stdin")
+		}
+
+		current, updated, err := single.EmbedFile(input)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(current, updated) {
+			continue
+		}
+
+		if *fCheck {
+			diff, err := mdextract.CheckFile(input, string(updated))
+			if err != nil {
+				return err
+			}
+
+			diffs = append(diffs, *diff)
+
+			continue
+		}
+
+		info, err := os.Stat(input)
+		if err != nil {
+			return err
+		}
+
+		if err := mdextract.WriteFile(input, updated, info.Mode().Perm(), false); err != nil {
+			return err
+		}
+	}
+
+	return reportDiffs(diffs)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ntnn/mdextract/pkg/mdextract"
+)
+
+// lintOptions are the options of the lint command besides the ones of
+// mdextract.Linter.
+type lintOptions struct {
+	linter       *mdextract.Linter
+	format       *string
+	excludePaths *[]string
+	config       *string
+}
+
+// newLintOptions returns the options and flags for the lint command
+// starting from linter, e.g. the one of the configuration file.
+func newLintOptions(linter mdextract.Linter) (*lintOptions, *flag.FlagSet) {
+	opts := &lintOptions{linter: &linter}
+	fs := opts.linter.FlagSet()
+
+	opts.format = fs.String("format", "text", "Output format: text, json or ndjson")
+	opts.excludePaths = excludePathFlag(fs)
+	opts.config = fs.String("config", "", "Configuration file with lint settings "+
+		"(default: "+mdextract.ConfigFileName+" in the current directory or its parents)")
+
+	return opts, fs
+}
+
+// runLint checks the code blocks in the given markdown files against
+// the documentation conventions and reports the findings. The allowed
+// tags, disabled rules, known attributes and inputs are read from the
+// configuration file if there is one, flags replace them.
+func runLint(args []string) error {
+	opts, fs := newLintOptions(mdextract.Linter{})
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	config, err := findConfig(*opts.config, false)
+	if err != nil {
+		return err
+	}
+
+	inputs := fs.Args()
+
+	if config != nil {
+		defaults, err := config.Profile("")
+		if err != nil {
+			return err
+		}
+
+		opts, fs = newLintOptions(config.Linter())
+		*opts.excludePaths = defaults.ExcludePaths
+
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+
+		inputs = fs.Args()
+		if len(inputs) == 0 {
+			inputs = defaults.Inputs
+		}
+	}
+
+	format, err := mdextract.ParseFormat(*opts.format)
+	if err != nil {
+		return err
+	}
+
+	if len(inputs) == 0 {
+		fs.PrintDefaults()
+		return errors.New("no input files specified")
+	}
+
+	inputs, err = findInputs(inputs, *opts.excludePaths)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := blockCache{}.read(&opts.linter.Single, inputs)
+	if err != nil {
+		return err
+	}
+
+	findings := opts.linter.Lint(blocks)
+
+	if err := mdextract.WriteFindings(os.Stdout, format, findings); err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(findings))
+	}
+
+	return nil
+}
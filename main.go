@@ -4,113 +4,410 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"slices"
 
 	"github.com/ntnn/mdextract/pkg/mdextract"
 )
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run() error {
-	multi := &mdextract.Multi{}
-	fs := multi.FlagSet()
+func run(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "embed":
+			return runEmbed(args[1:])
+		case "run":
+			return runBlocks(args[1:])
+		case "lint":
+			return runLint(args[1:])
+		}
+	}
+
+	return runExtract(args)
+}
+
+// excludePathFlag registers the repeatable -exclude-path flag.
+func excludePathFlag(fs *flag.FlagSet) *[]string {
+	excludePaths := &[]string{}
+	set := false
+
+	fs.Func("exclude-path", "Exclude discovered files matching the pattern, can be repeated", func(s string) error {
+		// replaces the patterns of a profile on the first occurrence
+		if !set {
+			*excludePaths, set = nil, true
+		}
+
+		*excludePaths = append(*excludePaths, s)
+
+		return nil
+	})
+
+	return excludePaths
+}
+
+// extractOptions are the options of the extraction besides the ones of
+// mdextract.Multi.
+type extractOptions struct {
+	multi        *mdextract.Multi
+	output       *string
+	isMulti      *bool
+	check        *bool
+	format       *string
+	excludePaths *[]string
+	config       *string
+	profile      *string
+	allProfiles  *bool
+}
+
+func newExtractOptions() (*extractOptions, *flag.FlagSet) {
+	opts := &extractOptions{multi: &mdextract.Multi{}}
+	fs := opts.multi.FlagSet()
+
+	opts.output = fs.String("output", "", "Output file ('-' for stdout, not compatible with -multi)")
+
+	opts.isMulti = fs.Bool("multi", false, "Extract multiple sections based on the file tag (not compatible with -output)")
+
+	opts.check = fs.Bool("check", false,
+		"Compare the extracted contents to the existing files without writing, fails if they differ")
+
+	opts.format = fs.String("format", "text", "Output format: text, json or ndjson. "+
+		"json and ndjson write one record per code block to -output or stdout")
+
+	opts.excludePaths = excludePathFlag(fs)
+
+	opts.config = fs.String("config", "", "Configuration file "+
+		"(default: "+mdextract.ConfigFileName+" in the current directory or its parents)")
+	opts.profile = fs.String("profile", "", "Profile of the configuration file to extract")
+	opts.allProfiles = fs.Bool("all-profiles", false, "Extract all profiles of the configuration file")
+
+	return opts, fs
+}
+
+// applyProfile sets the options to the values of the profile. It must
+// be called before parsing the flags so that flags override the
+// profile.
+func (opts *extractOptions) applyProfile(profile mdextract.Profile) error {
+	multi, err := profile.Multi()
+	if err != nil {
+		return err
+	}
+
+	isMulti, err := profile.IsMulti()
+	if err != nil {
+		return err
+	}
+
+	*opts.multi = multi
+	*opts.output = profile.Output
+	*opts.isMulti = isMulti
+	*opts.check = profile.Check
+	*opts.excludePaths = profile.ExcludePaths
+
+	if profile.Format != "" {
+		*opts.format = profile.Format
+	}
+
+	return nil
+}
+
+func runExtract(args []string) error {
+	opts, fs := newExtractOptions()
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	config, err := findConfig(*opts.config, *opts.profile != "" || *opts.allProfiles)
+	if err != nil {
+		return err
+	}
+
+	cache := blockCache{}
+
+	if config == nil {
+		return opts.extract(fs, fs.Args(), cache)
+	}
+
+	names := []string{*opts.profile}
+	if *opts.allProfiles {
+		names = config.ProfileNames()
+	}
+
+	for _, name := range names {
+		profile, err := config.Profile(name)
+		if err != nil {
+			return err
+		}
+
+		opts, fs, err := profileOptions(profile, args)
+		if err != nil {
+			return profileError(name, err)
+		}
+
+		inputs := fs.Args()
+		if len(inputs) == 0 {
+			inputs = profile.Inputs
+		}
+
+		if err := opts.extract(fs, inputs, cache); err != nil {
+			return profileError(name, err)
+		}
+	}
+
+	return nil
+}
 
-	fOutput := fs.String("output", "", "Output file ('-' for stdout, not compatible with -multi)")
+// profileOptions returns the options of the profile with the flags in
+// args applied on top, so that flags override the profile.
+func profileOptions(profile mdextract.Profile, args []string) (*extractOptions, *flag.FlagSet, error) {
+	opts, fs := newExtractOptions()
+	if err := opts.applyProfile(profile); err != nil {
+		return nil, nil, err
+	}
+
+	if err := parseFlags(fs, args); err != nil {
+		return nil, nil, err
+	}
 
-	fMulti := fs.Bool("multi", false, "Extract multiple sections based on the file tag (not compatible with -output)")
-	if err := fs.Parse(os.Args[1:]); err != nil {
+	return opts, fs, nil
+}
+
+// extract extracts the code blocks of the inputs according to the
+// options.
+func (opts *extractOptions) extract(fs *flag.FlagSet, args []string, cache blockCache) error {
+	multi := opts.multi
+
+	format, err := mdextract.ParseFormat(*opts.format)
+	if err != nil {
 		return err
 	}
 
-	if *fMulti && *fOutput != "" {
+	if format != mdextract.FormatText {
+		if *opts.check || multi.Append {
+			fs.PrintDefaults()
+			return errors.New("-check and -append can only be used with -format text")
+		}
+
+		inputs, err := findInputs(args, *opts.excludePaths)
+		if err != nil {
+			return err
+		}
+
+		blocks, err := cache.read(&multi.Single, inputs)
+		if err != nil {
+			return err
+		}
+
+		return doRecords(multi, *opts.isMulti, format, *opts.output, blocks)
+	}
+
+	if *opts.isMulti && *opts.output != "" {
 		fs.PrintDefaults()
 		return errors.New("-multi and -output cannot be used together")
 	}
 
-	if !*fMulti && *fOutput == "" {
+	if !*opts.isMulti && *opts.output == "" {
 		fs.PrintDefaults()
 		return errors.New("-multi or -output must be specified")
 	}
 
-	if fs.NArg() == 0 {
+	if *opts.check && (*opts.output == "-" || multi.Append) {
+		fs.PrintDefaults()
+		return errors.New("-check cannot be used with -output - or -append")
+	}
+
+	if len(args) == 0 {
 		fs.PrintDefaults()
 		return errors.New("no input files specified")
 	}
 
-	if *fMulti {
-		return doMulti(multi, fs.Args())
+	inputs, err := findInputs(args, *opts.excludePaths)
+	if err != nil {
+		return err
+	}
+
+	// blocks of all inputs are collected so that code blocks can
+	// require code blocks in other inputs and code blocks for the same
+	// file in different inputs are checked for conflicts
+	blocks, err := cache.read(&multi.Single, inputs)
+	if err != nil {
+		return err
+	}
+
+	if *opts.isMulti {
+		return doMulti(multi, blocks, *opts.check)
+	}
+
+	return doSingle(&multi.Single, *opts.output, multi.FileMode, multi.Append, *opts.check, blocks)
+}
+
+// findInputs expands the inputs, see mdextract.FindInputs. Empty
+// arguments are skipped. It fails if no input files are specified or
+// found.
+func findInputs(args, excludePaths []string) ([]string, error) {
+	args = slices.DeleteFunc(slices.Clone(args), func(arg string) bool {
+		return arg == ""
+	})
+
+	if len(args) == 0 {
+		return nil, errors.New("no input files specified")
+	}
+
+	inputs, err := mdextract.FindInputs(args, excludePaths)
+	if err != nil {
+		return nil, err
 	}
 
-	return doSingle(&multi.Single, *fOutput, multi.FileMode, fs.Args())
+	if len(inputs) == 0 {
+		return nil, errors.New("no input files found")
+	}
+
+	return inputs, nil
 }
 
-func doSingle(s *mdextract.Single, outputPath string, fileMode uint32, args []string) error {
-	f := os.Stdout
+// doRecords writes the records of the code blocks in the inputs in the
+// given format to the output file or stdout.
+func doRecords(
+	m *mdextract.Multi, multi bool, format mdextract.Format, outputPath string, all []mdextract.Block,
+) error {
+	var (
+		records []mdextract.Record
+		err     error
+	)
 
-	closeFn := func() error { return nil }
+	if multi {
+		records, err = m.Records(all)
+	} else {
+		records, err = m.Single.Records(all)
+	}
 
-	if outputPath != "-" {
-		var err error
+	if err != nil {
+		return err
+	}
 
-		f, err = os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(fileMode)) //nolint:gosec
-		if err != nil {
-			return err
-		}
+	if outputPath == "" || outputPath == "-" {
+		return mdextract.WriteRecords(os.Stdout, format, records)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := mdextract.WriteRecords(buf, format, records); err != nil {
+		return err
+	}
+
+	return mdextract.WriteFile(outputPath, buf.Bytes(), os.FileMode(m.FileMode), false)
+}
+
+func doSingle(
+	s *mdextract.Single, outputPath string, fileMode uint32, appendData, check bool, blocks []mdextract.Block,
+) error {
+	out, err := s.ExtractBlocks(blocks)
+	if err != nil {
+		return err
+	}
 
-		closeFn = f.Close
+	if outputPath == "-" {
+		_, err := os.Stdout.WriteString(out)
+		return err
 	}
 
-	for _, input := range args {
-		out, err := s.ExtractFromFile(input)
+	if check {
+		diff, err := mdextract.CheckFile(outputPath, out)
 		if err != nil {
 			return err
 		}
 
-		if _, err := f.WriteString(out); err != nil {
-			return err
+		if diff == nil {
+			return nil
 		}
+
+		return reportDiffs([]mdextract.Diff{*diff})
 	}
 
-	return closeFn()
+	return mdextract.WriteFile(outputPath, []byte(out), os.FileMode(fileMode), appendData)
 }
 
-// in contrast to Multi.ExtractFromFileAndWrite, this function does not
-// truncate files when writing. This allows parsing multiple files in
-// one go and accumulating their output in the same files.
-func doMulti(m *mdextract.Multi, args []string) error {
-	for _, input := range args {
-		out, err := m.ExtractFromFile(input)
-		if err != nil {
-			return err
-		}
+// readBlocks parses the given input file, or stdin if the input is "-".
+func readBlocks(s *mdextract.Single, input string) ([]mdextract.Block, error) {
+	if input == "-" {
+		return mdextract.BlocksFromReader(s.SourceName, os.Stdin)
+	}
+
+	return mdextract.BlocksFromFile(input)
+}
+
+// blockCache holds the blocks of the inputs by path so that each input
+// is only parsed once, e.g. when extracting multiple profiles.
+type blockCache map[string][]mdextract.Block
+
+// read returns the blocks of all inputs.
+func (cache blockCache) read(s *mdextract.Single, inputs []string) ([]mdextract.Block, error) {
+	ret := []mdextract.Block{}
+
+	for _, input := range inputs {
+		blocks, ok := cache[input]
+		if !ok {
+			var err error
 
-		for file, content := range out {
-			if err := writeFileNoTruncate(file, []byte(content), os.FileMode(m.FileMode)); err != nil {
-				return err
+			blocks, err = readBlocks(s, input)
+			if err != nil {
+				return nil, err
 			}
+
+			cache[input] = blocks
 		}
+
+		ret = append(ret, blocks...)
 	}
 
-	return nil
+	return ret, nil
 }
 
-// copied from os.WriteFile but without truncation.
-func writeFileNoTruncate(name string, data []byte, perm os.FileMode) error {
-	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, perm) //nolint:gosec
+// doMulti extracts the blocks of all inputs before writing, so multiple
+// inputs can contribute to the same files.
+func doMulti(m *mdextract.Multi, blocks []mdextract.Block, check bool) error {
+	contents, err := m.ExtractBlocks(blocks)
 	if err != nil {
 		return err
 	}
 
-	_, err = f.Write(data)
-	if err1 := f.Close(); err1 != nil && err == nil {
-		err = err1
+	if check {
+		diffs, err := m.Check(contents)
+		if err != nil {
+			return err
+		}
+
+		return reportDiffs(diffs)
+	}
+
+	modes, err := m.Modes(blocks)
+	if err != nil {
+		return err
+	}
+
+	return m.WriteModes(contents, modes)
+}
+
+// reportDiffs prints the diffs and returns an error if there are any.
+func reportDiffs(diffs []mdextract.Diff) error {
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	for _, diff := range diffs {
+		if _, err := os.Stdout.WriteString(diff.Unified); err != nil {
+			return err
+		}
 	}
 
-	return err
+	return fmt.Errorf("%d file(s) differ from the extracted contents", len(diffs))
 }
@@ -0,0 +1,552 @@
+package mdextract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Block is a code block found in a markdown document.
+type Block struct {
+	// Content is the literal content of the code block.
+	Content string
+	// Info is the raw info string of a fenced code block, e.g.
+	// "go file=main.go ci".
+	Info string
+	// Tags are the tags parsed from the info string, including the
+	// language.
+	Tags []string
+	// Attributes are the key=value pairs parsed from the info string.
+	Attributes map[string]string
+	// ID is the identifier of the block, e.g. `#setup` in Pandoc
+	// attributes or the chunk label in R Markdown and Quarto.
+	ID string
+	// Source is the path of the markdown document the block was
+	// found in. It is empty if the block was not read from a file.
+	Source string
+	// StartLine is the 1-based line of the opening fence, or of the
+	// first line for indented code blocks.
+	StartLine int
+	// EndLine is the 1-based line of the closing fence, or of the
+	// last line for indented code blocks.
+	EndLine int
+	// FenceChar is the character used for the fence, either '`' or
+	// '~'. It is zero for indented code blocks.
+	FenceChar byte
+	// Visibility is whether the block is rendered or hidden inside an
+	// HTML comment or a <details> element.
+	Visibility Visibility
+	// Unterminated is true if the closing fence is missing. The
+	// markdown parser reads such a block as a paragraph, Content holds
+	// the lines of the paragraph. Unterminated blocks are never
+	// extracted.
+	Unterminated bool
+	// Headings are the titles of the headings the block is nested
+	// under, starting with the outermost heading.
+	Headings []string
+}
+
+// Language returns the language of the block, which is the first
+// word of the info string if it is a tag.
+func (block Block) Language() string {
+	return parseInfo([]byte(block.Info)).language
+}
+
+// Blocks parses the given markdown data and returns all code blocks in
+// document order.
+//
+// HTML comments like "<!-- mdextract: tags=ci -->" hold directives
+// instead of hidden code blocks: "tags=a,b" adds tags and any other
+// key=value sets a default attribute for the following code blocks
+// until the next heading of the same or a higher level, "skip-next"
+// leaves out the next code block and "off" leaves out all code blocks
+// until "on".
+func Blocks(data []byte) ([]Block, error) {
+	return parseBlocks("", data)
+}
+
+// BlocksFromFile reads a markdown file from the given path and returns
+// all code blocks in document order.
+func BlocksFromFile(path string) ([]Block, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBlocks(path, data)
+}
+
+// BlocksFromReader reads markdown from r and returns all code blocks
+// in document order. name is used as the source of the blocks.
+func BlocksFromReader(name string, r io.Reader) ([]Block, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return parseBlocks(name, data)
+}
+
+func parseBlocks(source string, data []byte) ([]Block, error) {
+	p := &blockParser{
+		source:   source,
+		data:     parser.NormalizeNewlines(data),
+		line:     1,
+		controls: &controls{},
+	}
+
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	return p.blocks, nil
+}
+
+type heading struct {
+	level int
+	title string
+}
+
+// blockParser walks the markdown AST and collects code blocks.
+// gomarkdown does not record source positions, so the parser keeps
+// a cursor into the data and locates each node in the source as it
+// is visited. Nodes are visited in document order, so searching
+// forward from the cursor finds the right location.
+type blockParser struct {
+	source string
+	data   []byte
+	// line is the line number of data[0] in the source document.
+	line int
+	// offset is the position in data up to which nodes have been
+	// located.
+	offset     int
+	visibility Visibility
+	// comments are the ranges of HTML comments in data that the
+	// parser does not recognize as HTML blocks.
+	comments [][2]int
+	headings []heading
+	controls *controls
+	blocks   []Block
+}
+
+func (p *blockParser) parse() error {
+	var err error
+
+	node := markdown.Parse(p.data, nil)
+	p.comments = commentRanges(p.data)
+
+	ast.WalkFunc(node, ast.NodeVisitorFunc(func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+
+		switch n := node.(type) {
+		case *ast.Heading:
+			p.heading(n)
+
+			return ast.SkipChildren
+		case *ast.CodeBlock:
+			p.codeBlock(n)
+		case *ast.Paragraph:
+			if err = p.paragraph(n); err != nil {
+				return ast.Terminate
+			}
+
+			return ast.SkipChildren
+		case *ast.HTMLBlock:
+			// an HTML block might be a comment with a code block that
+			// should only be executed in e.g. CI
+			if err = p.htmlBlock(n); err != nil {
+				return ast.Terminate
+			}
+		}
+
+		return ast.GoToNext
+	}))
+
+	return err
+}
+
+func (p *blockParser) heading(n *ast.Heading) {
+	for len(p.headings) > 0 && p.headings[len(p.headings)-1].level >= n.Level {
+		p.headings = p.headings[:len(p.headings)-1]
+	}
+
+	p.headings = append(p.headings, heading{level: n.Level, title: nodeText(n)})
+	p.controls.heading(n.Level)
+}
+
+// level returns the level of the innermost heading, zero if there is
+// none.
+func (p *blockParser) level() int {
+	if len(p.headings) == 0 {
+		return 0
+	}
+
+	return p.headings[len(p.headings)-1].level
+}
+
+func (p *blockParser) headingPath() []string {
+	ret := make([]string, 0, len(p.headings))
+	for _, h := range p.headings {
+		ret = append(ret, h.title)
+	}
+
+	return ret
+}
+
+func (p *blockParser) codeBlock(n *ast.CodeBlock) {
+	block := Block{
+		Content:    string(n.Literal),
+		Info:       string(n.Info),
+		Source:     p.source,
+		Visibility: p.visibility,
+		Headings:   p.headingPath(),
+	}
+
+	if n.IsFenced {
+		block.StartLine, block.EndLine, block.FenceChar, block.Info = p.locateFence(block.Info)
+	} else {
+		block.StartLine, block.EndLine = p.locateIndented(n.Literal)
+	}
+
+	if p.inComment(p.offset - 1) {
+		block.Visibility = VisibilityComment
+	}
+
+	p.addBlock(block)
+}
+
+// paragraph handles HTML comments starting a line of a paragraph like
+// HTML blocks. The markdown parser reads comments as part of
+// a paragraph if e.g. they are indented or at the end of the document.
+func (p *blockParser) paragraph(n *ast.Paragraph) error {
+	for _, child := range n.Children {
+		span, ok := child.(*ast.HTMLSpan)
+		if !ok || !bytes.HasPrefix(span.Literal, commentOpen) {
+			continue
+		}
+
+		i := bytes.Index(p.data[p.offset:], span.Literal)
+		if i < 0 || !p.commentStart(p.offset+i) {
+			continue
+		}
+
+		if err := p.html(span.Literal); err != nil {
+			return err
+		}
+	}
+
+	p.unterminated(n)
+
+	return nil
+}
+
+// unterminated adds a paragraph starting with a fence as an
+// unterminated code block, the markdown parser falls back to
+// a paragraph if the closing fence is missing.
+func (p *blockParser) unterminated(n *ast.Paragraph) {
+	text, ok := ast.GetFirstChild(n).(*ast.Text)
+	if !ok {
+		return
+	}
+
+	first, content, _ := strings.Cut(string(text.Literal), "\n")
+
+	marker, info, ok := parseFenceLine([]byte(first))
+	if !ok {
+		return
+	}
+
+	start, _, rawInfo := p.findFence(info, true)
+	if start < 0 {
+		return
+	}
+
+	if content != "" {
+		content += "\n"
+	}
+
+	_, p.offset = nextLine(p.data, start)
+
+	block := Block{
+		Content:      content,
+		Info:         rawInfo,
+		Source:       p.source,
+		StartLine:    p.lineAt(start),
+		EndLine:      p.lineAt(start) + strings.Count(content, "\n"),
+		FenceChar:    marker[0],
+		Visibility:   p.visibility,
+		Headings:     p.headingPath(),
+		Unterminated: true,
+	}
+
+	if p.inComment(start) {
+		block.Visibility = VisibilityComment
+	}
+
+	p.addBlock(block)
+}
+
+// addBlock parses the info string of block and adds it unless a
+// directive leaves it out.
+func (p *blockParser) addBlock(block Block) {
+	info := parseInfo([]byte(block.Info))
+	if info.braced {
+		info.parseCellOptions(block.Content)
+	}
+
+	block.Tags = info.tags
+	block.Attributes = info.attrs
+	block.ID = info.id
+
+	// the fence is located regardless to keep the cursor in sync
+	if !p.controls.accept() {
+		return
+	}
+
+	p.controls.annotate(&block)
+	p.blocks = append(p.blocks, block)
+}
+
+func (p *blockParser) htmlBlock(n *ast.HTMLBlock) error {
+	return p.html(n.Literal)
+}
+
+// html parses the comments and <details> elements in literal for code
+// blocks and directives.
+func (p *blockParser) html(literal []byte) error {
+	start := p.offset
+	if i := bytes.Index(p.data[p.offset:], literal); i >= 0 {
+		start += i
+		p.offset = start + len(literal)
+	}
+
+	// Only comments and <details> elements are parsed for code
+	// blocks, other HTML is left alone
+	for _, element := range htmlElements(literal) {
+		offset := start + element.offset
+
+		visibility := VisibilityComment
+		if element.details && p.visibility != VisibilityComment {
+			visibility = VisibilityDetails
+		}
+
+		// A comment with mdextract directives controls the
+		// extraction of the following code blocks instead of hiding
+		// any
+		if directives, ok := parseControl(element.content); ok && !element.details {
+			if err := p.controls.apply(directives, p.level()); err != nil {
+				return fmt.Errorf("%s:%d: %w", p.source, p.lineAt(offset), err)
+			}
+
+			continue
+		}
+
+		child := &blockParser{
+			source:     p.source,
+			data:       element.content,
+			line:       p.lineAt(offset),
+			visibility: visibility,
+			headings:   append([]heading{}, p.headings...),
+			controls:   p.controls,
+		}
+
+		if err := child.parse(); err != nil {
+			return err
+		}
+
+		p.blocks = append(p.blocks, child.blocks...)
+	}
+
+	return nil
+}
+
+// commentStart reports whether an HTML comment the markdown parser did
+// not recognize starts at offset.
+func (p *blockParser) commentStart(offset int) bool {
+	for _, comment := range p.comments {
+		if comment[0] == offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inComment reports whether offset is inside an HTML comment the
+// markdown parser did not recognize.
+func (p *blockParser) inComment(offset int) bool {
+	for _, comment := range p.comments {
+		if offset >= comment[0] && offset < comment[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lineAt returns the line number in the source document of the given
+// offset in data.
+func (p *blockParser) lineAt(offset int) int {
+	return p.line + bytes.Count(p.data[:offset], []byte("\n"))
+}
+
+// locateFence finds the opening and closing fence of a fenced code
+// block with the given info string and returns their line numbers, the
+// fence character and the info string as written in the source.
+func (p *blockParser) locateFence(info string) (int, int, byte, string) {
+	start, marker, rawInfo := p.findFence(info, true)
+	if start < 0 {
+		// the info string is unescaped by the parser and may not
+		// match the source, fall back to the next opening fence
+		start, marker, rawInfo = p.findFence(info, false)
+	}
+
+	if start < 0 {
+		line := p.lineAt(p.offset)
+		return line, line, 0, info
+	}
+
+	startLine := p.lineAt(start)
+	_, off := nextLine(p.data, start)
+
+	for off < len(p.data) {
+		line, next := nextLine(p.data, off)
+		if string(bytes.TrimSpace(stripPrefix(line))) == marker {
+			p.offset = next
+			return startLine, p.lineAt(off), marker[0], rawInfo
+		}
+
+		off = next
+	}
+
+	p.offset = len(p.data)
+
+	return startLine, p.lineAt(len(p.data)), marker[0], rawInfo
+}
+
+// findFence returns the offset of the next opening fence after the
+// cursor, its marker and info string. If exact is true, the info
+// string of the fence must match info.
+func (p *blockParser) findFence(info string, exact bool) (int, string, string) {
+	for off := p.offset; off < len(p.data); {
+		line, next := nextLine(p.data, off)
+		if marker, lineInfo, ok := parseFenceLine(line); ok && (!exact || sameInfo(lineInfo, info)) {
+			return off, marker, lineInfo
+		}
+
+		off = next
+	}
+
+	return -1, "", ""
+}
+
+// sameInfo reports whether the info string in the source matches the
+// info string of the parser, which strips surrounding curly braces.
+func sameInfo(source, parsed string) bool {
+	if source == parsed {
+		return true
+	}
+
+	if inner, ok := strings.CutPrefix(source, "{"); ok {
+		inner, ok = strings.CutSuffix(inner, "}")
+
+		return ok && strings.TrimSpace(inner) == parsed
+	}
+
+	return false
+}
+
+// locateIndented finds the lines of an indented code block with the
+// given literal.
+func (p *blockParser) locateIndented(literal []byte) (int, int) {
+	first, _ := nextLine(literal, 0)
+	first = bytes.TrimSpace(first)
+	lines := bytes.Count(bytes.TrimRight(literal, "\n"), []byte("\n"))
+
+	for off := p.offset; off < len(p.data); {
+		line, next := nextLine(p.data, off)
+		if bytes.Equal(bytes.TrimSpace(stripPrefix(line)), first) {
+			start := p.lineAt(off)
+			p.offset = next
+
+			return start, start + lines
+		}
+
+		off = next
+	}
+
+	line := p.lineAt(p.offset)
+
+	return line, line + lines
+}
+
+// nextLine returns the line starting at offset without the trailing
+// newline and the offset of the following line.
+func nextLine(data []byte, offset int) ([]byte, int) {
+	end := bytes.IndexByte(data[offset:], '\n')
+	if end < 0 {
+		return data[offset:], len(data)
+	}
+
+	return data[offset : offset+end], offset + end + 1
+}
+
+// stripPrefix removes indentation and blockquote markers from a line.
+func stripPrefix(line []byte) []byte {
+	return bytes.TrimLeft(line, " \t>")
+}
+
+// parseFenceLine checks if line is an opening fence and returns the
+// fence marker and the info string.
+func parseFenceLine(line []byte) (string, string, bool) {
+	line = stripPrefix(line)
+	if len(line) == 0 || (line[0] != '`' && line[0] != '~') {
+		return "", "", false
+	}
+
+	size := 0
+	for size < len(line) && line[size] == line[0] {
+		size++
+	}
+
+	if size < 3 { //nolint:mnd
+		return "", "", false
+	}
+
+	info := strings.TrimSpace(string(line[size:]))
+	if line[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+
+	return string(line[:size]), info, true
+}
+
+// nodeText returns the plain text of a node and its children.
+func nodeText(node ast.Node) string {
+	builder := &strings.Builder{}
+
+	ast.WalkFunc(node, ast.NodeVisitorFunc(func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+
+		switch n := node.(type) {
+		case *ast.Text:
+			builder.Write(n.Literal)
+		case *ast.Code:
+			builder.Write(n.Literal)
+		}
+
+		return ast.GoToNext
+	}))
+
+	return strings.TrimSpace(builder.String())
+}
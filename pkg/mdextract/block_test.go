@@ -0,0 +1,234 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input    []string
+		expected []Block
+	}{
+		"empty": {
+			input:    []string{},
+			expected: nil,
+		},
+		"fenced": {
+			input: []string{
+				"# title",
+				"",
+				"```go file=main.go ci",
+				"package main",
+				"```",
+			},
+			expected: []Block{
+				{
+					Content:    "package main\n",
+					Info:       "go file=main.go ci",
+					Tags:       []string{"go", "ci"},
+					Attributes: map[string]string{"file": "main.go"},
+					StartLine:  3,
+					EndLine:    5,
+					FenceChar:  '`',
+					Headings:   []string{"title"},
+				},
+			},
+		},
+		"tilde fence": {
+			input: []string{
+				"~~~~bash",
+				"echo ```",
+				"~~~~",
+			},
+			expected: []Block{
+				{
+					Content:    "echo ```\n",
+					Info:       "bash",
+					Tags:       []string{"bash"},
+					Attributes: map[string]string{},
+					StartLine:  1,
+					EndLine:    3,
+					FenceChar:  '~',
+					Headings:   []string{},
+				},
+			},
+		},
+		"indented": {
+			input: []string{
+				"text",
+				"",
+				"    ```go",
+				"    code",
+				"    ```",
+				"",
+				"```go",
+				"code",
+				"```",
+			},
+			expected: []Block{
+				{
+					Content:    "```go\ncode\n```\n",
+					Attributes: map[string]string{},
+					StartLine:  3,
+					EndLine:    5,
+					Headings:   []string{},
+				},
+				{
+					Content:    "code\n",
+					Info:       "go",
+					Tags:       []string{"go"},
+					Attributes: map[string]string{},
+					StartLine:  7,
+					EndLine:    9,
+					FenceChar:  '`',
+					Headings:   []string{},
+				},
+			},
+		},
+		"pandoc attributes": {
+			input: []string{
+				"```{.python #setup file=run.py}",
+				"print(1)",
+				"```",
+				"",
+				"```{r label, eval=FALSE}",
+				"1",
+				"```",
+				"",
+				"```{python}",
+				"#| label: greet",
+				"print(2)",
+				"```",
+			},
+			expected: []Block{
+				{
+					Content:    "print(1)\n",
+					Info:       "{.python #setup file=run.py}",
+					Tags:       []string{"python"},
+					Attributes: map[string]string{"file": "run.py"},
+					ID:         "setup",
+					StartLine:  1,
+					EndLine:    3,
+					FenceChar:  '`',
+					Headings:   []string{},
+				},
+				{
+					Content:    "1\n",
+					Info:       "{r label, eval=FALSE}",
+					Tags:       []string{"r"},
+					Attributes: map[string]string{"eval": "FALSE"},
+					ID:         "label",
+					StartLine:  5,
+					EndLine:    7,
+					FenceChar:  '`',
+					Headings:   []string{},
+				},
+				{
+					Content:    "#| label: greet\nprint(2)\n",
+					Info:       "{python}",
+					Tags:       []string{"python"},
+					Attributes: map[string]string{},
+					ID:         "greet",
+					StartLine:  9,
+					EndLine:    12,
+					FenceChar:  '`',
+					Headings:   []string{},
+				},
+			},
+		},
+		"comment and headings": {
+			input: []string{
+				"# one",
+				"## two",
+				"### three",
+				"## four",
+				"",
+				"<!--",
+				"```sh",
+				"hidden",
+				"```",
+				"-->",
+				"",
+			},
+			expected: []Block{
+				{
+					Content:    "hidden\n",
+					Info:       "sh",
+					Tags:       []string{"sh"},
+					Attributes: map[string]string{},
+					StartLine:  7,
+					EndLine:    9,
+					FenceChar:  '`',
+					Visibility: VisibilityComment,
+					Headings:   []string{"one", "four"},
+				},
+			},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := Blocks([]byte(strings.Join(cas.input, "\n")))
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, blocks)
+		})
+	}
+}
+
+func TestBlocksFromFile(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := BlocksFromFile("single.md")
+	require.NoError(t, err)
+	require.Len(t, blocks, 9)
+
+	lines := [][2]int{}
+	for _, block := range blocks {
+		assert.Equal(t, "single.md", block.Source)
+		assert.Equal(t, []string{"header", "subheader"}, block.Headings)
+
+		lines = append(lines, [2]int{block.StartLine, block.EndLine})
+	}
+
+	assert.Equal(t, [][2]int{
+		{5, 7}, {9, 11}, {13, 15}, {17, 19},
+		{26, 28}, {29, 31}, {32, 34},
+		{37, 39}, {41, 43},
+	}, lines)
+}
+
+func TestBlock_Language(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		info     string
+		expected string
+	}{
+		"empty":          {"", ""},
+		"language":       {"go", "go"},
+		"language, tags": {"go ci", "go"},
+		"only file":      {"file=main.go", ""},
+		"file first":     {"file=main.go go", ""},
+		"pandoc":         {"{.python #setup}", "python"},
+		"pandoc prefix":  {"python {.numberLines}", "python"},
+		"rmarkdown":      {"{r setup, eval=FALSE}", "r"},
+		"quarto":         {"{python}", "python"},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			block := Block{Info: cas.info}
+			assert.Equal(t, cas.expected, block.Language())
+		})
+	}
+}
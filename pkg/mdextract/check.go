@@ -0,0 +1,141 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const diffContext = 3
+
+// Diff is a mismatch between extracted contents and an existing file.
+type Diff struct {
+	// Path is the path of the file.
+	Path string
+	// Unified is the unified diff from the existing file to the
+	// extracted contents.
+	Unified string
+}
+
+// unifiedDiff returns the unified diff between the existing and the
+// expected contents of the file at path, or an empty string if they
+// are equal.
+func unifiedDiff(path string, existing []byte, exists bool, expected string) (string, error) {
+	if exists && string(existing) == expected {
+		return "", nil
+	}
+
+	from := path
+	if !exists {
+		from = "/dev/null"
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(existing)),
+		B:        splitLines(expected),
+		FromFile: from,
+		ToFile:   path,
+		Context:  diffContext,
+	})
+}
+
+// splitLines splits s into lines for difflib, keeping the newlines.
+// Like diff(1) a missing newline at the end is marked explicitly.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+
+	lines[len(lines)-1] += "\n\\ No newline at end of file\n"
+
+	return lines
+}
+
+// CheckFile compares expected to the contents of the file at path
+// without writing. It returns nil if they are equal. A missing file is
+// compared as empty.
+func CheckFile(path string, expected string) (*Diff, error) {
+	existing, err := os.ReadFile(path) //nolint:gosec
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	exists := err == nil
+
+	unified, err := unifiedDiff(path, existing, exists, expected)
+	if err != nil || unified == "" {
+		return nil, err
+	}
+
+	return &Diff{Path: path, Unified: unified}, nil
+}
+
+// readExisting reads the file with the given name in root. It reports
+// whether the file exists.
+func readExisting(root *os.Root, name string) ([]byte, bool, error) {
+	if root == nil {
+		return nil, false, nil
+	}
+
+	data, err := root.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Check compares the contents to the files inside OutputDir without
+// writing, see Write. It returns the mismatching files in sorted
+// order.
+func (multi Multi) Check(contents map[string]string) ([]Diff, error) {
+	ret := []Diff{}
+
+	for _, name := range slices.Sorted(maps.Keys(contents)) {
+		if err := checkPath(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// a missing output directory is compared as empty
+	root, err := os.OpenRoot(multi.outputDir())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if root != nil {
+		defer root.Close() //nolint:errcheck
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(contents)) {
+		existing, exists, err := readExisting(root, filepath.FromSlash(name))
+		if err != nil {
+			return nil, fmt.Errorf("file %q: %w", name, err)
+		}
+
+		path := filepath.Join(multi.outputDir(), filepath.FromSlash(name))
+
+		unified, err := unifiedDiff(path, existing, exists, contents[name])
+		if err != nil {
+			return nil, err
+		}
+
+		if unified != "" {
+			ret = append(ret, Diff{Path: path, Unified: unified})
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,80 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFile(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "out.sh")
+
+	diff, err := CheckFile(p, "echo ok\n")
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "--- /dev/null\n+++ "+p+"\n@@ -0,0 +1 @@\n+echo ok\n", diff.Unified)
+
+	require.NoError(t, os.WriteFile(p, []byte("echo ok\n"), 0o600))
+
+	diff, err = CheckFile(p, "echo ok\n")
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+
+	diff, err = CheckFile(p, "echo ok")
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "--- "+p+"\n+++ "+p+"\n@@ -1 +1 @@\n-echo ok\n+echo ok\n\\ No newline at end of file\n", diff.Unified)
+}
+
+func TestMulti_Check(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+	multi := &Multi{OutputDir: outputDir}
+
+	contents := map[string]string{
+		"same.txt":    "same\n",
+		"changed.txt": "one\ntwo\n",
+		"sub/new.txt": "new\n",
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "same.txt"), []byte("same\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "changed.txt"), []byte("one\nthree\n"), 0o600))
+
+	diffs, err := multi.Check(contents)
+	require.NoError(t, err)
+	require.Len(t, diffs, 2)
+
+	assert.Equal(t, filepath.Join(outputDir, "changed.txt"), diffs[0].Path)
+	assert.Contains(t, diffs[0].Unified, "-three\n+two\n")
+	assert.Equal(t, filepath.Join(outputDir, "sub", "new.txt"), diffs[1].Path)
+	assert.Contains(t, diffs[1].Unified, "--- /dev/null\n")
+
+	// nothing was written
+	_, err = os.Stat(filepath.Join(outputDir, "sub"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, multi.Write(contents))
+
+	diffs, err = multi.Check(contents)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestMulti_Check_MissingOutputDir(t *testing.T) {
+	t.Parallel()
+
+	multi := &Multi{OutputDir: filepath.Join(t.TempDir(), "missing")}
+
+	diffs, err := multi.Check(map[string]string{"a.txt": "a\n"})
+	require.NoError(t, err)
+	assert.Len(t, diffs, 1)
+
+	_, err = multi.Check(map[string]string{"../a.txt": "a\n"})
+	require.ErrorIs(t, err, ErrPathEscapes)
+}
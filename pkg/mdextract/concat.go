@@ -0,0 +1,104 @@
+package mdextract
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// commentPrefix returns the line comment of the given language or an
+// empty string if the language is unknown or has no comment syntax.
+func commentPrefix(lang string) string {
+	switch commentStyleOf(lang) {
+	case commentGo, commentC, commentSlash:
+		return "//"
+	case commentHash:
+		return "#"
+	case commentDash:
+		return "--"
+	case commentNone:
+	}
+
+	return ""
+}
+
+// header returns a comment describing where block comes from, e.g.
+// "# --- README.md:42 (bash ci) ---". It returns an empty string if
+// the language is unknown or has no comment syntax.
+func (block Block) header() string {
+	prefix := commentPrefix(block.Language())
+	if prefix == "" {
+		return ""
+	}
+
+	origin := fmt.Sprintf("%s:%d", block.Source, block.StartLine)
+	if len(block.Tags) > 0 {
+		origin += " (" + strings.Join(block.Tags, " ") + ")"
+	}
+
+	return fmt.Sprintf("%s --- %s ---\n", prefix, origin)
+}
+
+// parseSeparator parses a separator template, see Single.Separator.
+func parseSeparator(s string) (*template.Template, error) {
+	return template.New("separator").Option("missingkey=error").Parse(s)
+}
+
+// decorate returns the content of block prefixed with the header and
+// line directive if enabled. A shebang line is kept as the first
+// line.
+func (single Single) decorate(block Block) string {
+	lang := block.Language()
+	line := block.contentLine()
+	content := block.Content
+
+	prefix := ""
+	if single.Headers {
+		prefix += block.header()
+	}
+
+	if !single.LineDirectives && prefix == "" {
+		return content
+	}
+
+	shebang := ""
+	if strings.HasPrefix(content, "#!") {
+		shebang, content, _ = strings.Cut(content, "\n")
+		shebang += "\n"
+		line++
+	}
+
+	if single.LineDirectives {
+		prefix += lineDirective(lang, block.Source, line)
+	}
+
+	return shebang + prefix + content
+}
+
+// concat concatenates the contents of the blocks. Each block starts on
+// its own line and is preceded by the separator if it is not the
+// first.
+func (single Single) concat(blocks []Block) (string, error) {
+	separator, err := parseSeparator(single.Separator)
+	if err != nil {
+		return "", fmt.Errorf("separator: %w", err)
+	}
+
+	builder := &strings.Builder{}
+
+	for i, block := range blocks {
+		if i > 0 {
+			if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+				builder.WriteString("\n")
+			}
+
+			if err := separator.Execute(builder, block); err != nil {
+				return "", fmt.Errorf("%s:%d: separator: %w", block.Source, block.StartLine, err)
+			}
+		}
+
+		builder.WriteString(single.decorate(block))
+	}
+
+	return builder.String(), nil
+}
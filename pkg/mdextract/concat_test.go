@@ -0,0 +1,140 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlock_header(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		tags     []string
+		expected string
+	}{
+		"go":      {[]string{"go"}, "// --- README.md:42 (go) ---\n"},
+		"c":       {[]string{"c"}, "// --- README.md:42 (c) ---\n"},
+		"bash":    {[]string{"bash", "ci"}, "# --- README.md:42 (bash ci) ---\n"},
+		"js":      {[]string{"js"}, "// --- README.md:42 (js) ---\n"},
+		"sql":     {[]string{"sql"}, "-- --- README.md:42 (sql) ---\n"},
+		"json":    {[]string{"json"}, ""},
+		"unknown": {[]string{"unknown"}, ""},
+		"empty":   {nil, ""},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			block := Block{Info: strings.Join(cas.tags, " "), Tags: cas.tags, Source: "README.md", StartLine: 42}
+			assert.Equal(t, cas.expected, block.header())
+		})
+	}
+}
+
+func TestSingle_concat(t *testing.T) {
+	t.Parallel()
+
+	blocks := []Block{
+		{Content: "echo one", Info: "sh", Tags: []string{"sh"}, Source: "doc.md", StartLine: 1, FenceChar: '`'},
+		{
+			Content: "#!/bin/sh\necho two\n", Info: "sh ci", Tags: []string{"sh", "ci"},
+			Source: "doc.md", StartLine: 5, FenceChar: '`',
+		},
+		{Content: "{}\n", Info: "json", Tags: []string{"json"}, Source: "doc.md", StartLine: 10, FenceChar: '`'},
+	}
+
+	cases := map[string]struct {
+		single   Single
+		expected string
+	}{
+		"default": {
+			Single{},
+			"echo one\n#!/bin/sh\necho two\n{}\n",
+		},
+		"separator": {
+			Single{Separator: "\n# {{ .Source }}:{{ .StartLine }}\n"},
+			"echo one\n\n# doc.md:5\n#!/bin/sh\necho two\n\n# doc.md:10\n{}\n",
+		},
+		"headers": {
+			Single{Headers: true},
+			strings.Join([]string{
+				"# --- doc.md:1 (sh) ---",
+				"echo one",
+				"#!/bin/sh",
+				"# --- doc.md:5 (sh ci) ---",
+				"echo two",
+				"{}",
+				"",
+			}, "\n"),
+		},
+		"headers and line directives": {
+			Single{Headers: true, LineDirectives: true},
+			strings.Join([]string{
+				"# --- doc.md:1 (sh) ---",
+				"# doc.md:2",
+				"echo one",
+				"#!/bin/sh",
+				"# --- doc.md:5 (sh ci) ---",
+				"# doc.md:7",
+				"echo two",
+				"{}",
+				"",
+			}, "\n"),
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			extracted, err := cas.single.concat(blocks)
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, extracted)
+		})
+	}
+}
+
+func TestSingle_concat_InvalidSeparator(t *testing.T) {
+	t.Parallel()
+
+	blocks := []Block{{Content: "a\n"}, {Content: "b\n", Source: "doc.md", StartLine: 3}}
+
+	_, err := Single{Separator: "{{ .Source"}.concat(blocks)
+	require.Error(t, err)
+
+	_, err = Single{Separator: "{{ .Unknown }}"}.concat(blocks)
+	require.ErrorContains(t, err, "doc.md:3: separator")
+}
+
+func TestMulti_Extract_Headers(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```go file=main.go",
+		"package main",
+		"```",
+		"",
+		"```go file=main.go",
+		"func main() {}",
+		"```",
+		"",
+	}, "\n")
+
+	multi := &Multi{Single: Single{Headers: true, Separator: "\n", SourceName: "doc.md"}}
+	extracted, err := multi.Extract([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"main.go": strings.Join([]string{
+			"// --- doc.md:1 (go) ---",
+			"package main",
+			"",
+			"// --- doc.md:5 (go) ---",
+			"func main() {}",
+			"",
+		}, "\n"),
+	}, extracted)
+}
@@ -0,0 +1,394 @@
+package mdextract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the configuration file, see FindConfig.
+const ConfigFileName = ".mdextract.yaml"
+
+var (
+	// ErrNoConfig is returned if no configuration file is found.
+	ErrNoConfig = errors.New("no " + ConfigFileName + " found")
+	// ErrUnknownProfile is returned for profiles not in the
+	// configuration.
+	ErrUnknownProfile = errors.New("unknown profile")
+	// ErrUnknownMode is returned for modes other than single and
+	// multi.
+	ErrUnknownMode = errors.New("unknown mode")
+)
+
+// Modes of a Profile.
+const (
+	ModeSingle = "single"
+	ModeMulti  = "multi"
+)
+
+// Profile is a set of options for extracting code blocks, see Config.
+type Profile struct {
+	// Name is the name of the profile, empty for the defaults.
+	Name string `yaml:"-"`
+
+	// Inputs are the markdown files, directories or glob patterns to
+	// extract from, see FindInputs.
+	Inputs []string `yaml:"inputs"`
+	// ExcludePaths are patterns of discovered files to skip.
+	ExcludePaths []string `yaml:"exclude-paths"`
+	// Mode is either ModeSingle or ModeMulti.
+	// Default: ModeSingle
+	Mode string `yaml:"mode"`
+	// Output is the file written in single mode, "-" for stdout.
+	Output string `yaml:"output"`
+	// OutputDir is the directory files are written to in multi mode.
+	OutputDir string `yaml:"output-dir"`
+	// Format is the output format, see ParseFormat.
+	Format string `yaml:"format"`
+	// FileMode is the mode of written files in octal, e.g. "0755".
+	FileMode string `yaml:"file-mode"`
+	// Append appends to existing files instead of replacing them.
+	Append bool `yaml:"append"`
+	// Executable makes files starting with a shebang executable.
+	Executable bool `yaml:"executable"`
+	// Check compares the extracted contents to the existing files
+	// instead of writing them.
+	Check bool `yaml:"check"`
+
+	Tags        []string `yaml:"tags"`
+	ExcludeTags []string `yaml:"exclude-tags"`
+	// Filter is a filter expression, see ParseFilter.
+	Filter string `yaml:"filter"`
+	// Sections are heading patterns, see ParseSection.
+	Sections []string `yaml:"sections"`
+	// Visibility are visibilities, see ParseVisibility.
+	Visibility []string `yaml:"visibility"`
+
+	LineDirectives   bool   `yaml:"line-directives"`
+	Headers          bool   `yaml:"headers"`
+	Separator        string `yaml:"separator"`
+	ExpandReferences bool   `yaml:"expand-references"`
+
+	Template bool              `yaml:"template"`
+	Vars     map[string]string `yaml:"vars"`
+	// VarsFile is a file with NAME=value lines, Vars take precedence.
+	VarsFile   string `yaml:"vars-file"`
+	VarsEnv    bool   `yaml:"vars-env"`
+	StrictVars bool   `yaml:"strict-vars"`
+
+	Strict          bool     `yaml:"strict"`
+	KnownAttributes []string `yaml:"known-attributes"`
+}
+
+// Single returns a Single configured by the profile.
+func (p Profile) Single() (Single, error) {
+	single := Single{
+		Tags:             p.Tags,
+		ExcludeTags:      p.ExcludeTags,
+		LineDirectives:   p.LineDirectives,
+		Headers:          p.Headers,
+		Separator:        p.Separator,
+		ExpandReferences: p.ExpandReferences,
+		Template: Template{
+			Enabled: p.Template,
+			Env:     p.VarsEnv,
+			Strict:  p.StrictVars,
+		},
+		Strict:          p.Strict,
+		KnownAttributes: p.KnownAttributes,
+		SourceName:      "stdin",
+	}
+
+	if p.Filter != "" {
+		filter, err := ParseFilter(p.Filter)
+		if err != nil {
+			return Single{}, err
+		}
+
+		single.Filter = filter
+	}
+
+	if len(p.Sections) > 0 {
+		sections := filterOr{}
+
+		for _, pattern := range p.Sections {
+			section, err := ParseSection(pattern)
+			if err != nil {
+				return Single{}, err
+			}
+
+			sections = append(sections, section)
+		}
+
+		single.Section = sections
+	}
+
+	if len(p.Visibility) > 0 {
+		visibility, err := ParseVisibility(strings.Join(p.Visibility, ","))
+		if err != nil {
+			return Single{}, err
+		}
+
+		single.Visibility = visibility
+	}
+
+	if _, err := parseSeparator(p.Separator); err != nil {
+		return Single{}, err
+	}
+
+	for name, value := range p.Vars {
+		single.setVar(name, value, true)
+	}
+
+	if p.VarsFile != "" {
+		vars, err := ReadVarsFile(p.VarsFile)
+		if err != nil {
+			return Single{}, err
+		}
+
+		for name, value := range vars {
+			single.setVar(name, value, false)
+		}
+	}
+
+	return single, nil
+}
+
+// Multi returns a Multi configured by the profile.
+func (p Profile) Multi() (Multi, error) {
+	single, err := p.Single()
+	if err != nil {
+		return Multi{}, err
+	}
+
+	multi := Multi{
+		Single:     single,
+		OutputDir:  p.OutputDir,
+		Append:     p.Append,
+		Executable: p.Executable,
+	}
+
+	if p.FileMode != "" {
+		mode, err := parseMode(p.FileMode)
+		if err != nil {
+			return Multi{}, err
+		}
+
+		multi.FileMode = uint32(mode)
+	}
+
+	return multi, nil
+}
+
+// IsMulti reports whether the profile extracts to multiple files.
+func (p Profile) IsMulti() (bool, error) {
+	switch p.Mode {
+	case "", ModeSingle:
+		return false, nil
+	case ModeMulti:
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w %q, expected %s or %s", ErrUnknownMode, p.Mode, ModeSingle, ModeMulti)
+	}
+}
+
+// LintConfig are the project settings of the Linter.
+type LintConfig struct {
+	// AllowedTags are the tags code blocks may have, see
+	// Linter.AllowedTags.
+	AllowedTags []string `yaml:"allowed-tags"`
+	// Disable are the rules not to check.
+	Disable []string `yaml:"disable"`
+}
+
+// Config is the project configuration with defaults, named profiles
+// and lint settings, e.g.:
+//
+//	defaults:
+//	  inputs: [README.md, docs/]
+//	  strict: true
+//	profiles:
+//	  ci:
+//	    tags: [ci]
+//	    output: ci.sh
+//	  examples:
+//	    mode: multi
+//	    output-dir: examples
+//	lint:
+//	  allowed-tags: [ci, slow]
+//	  disable: [indented]
+//
+// Profiles inherit the defaults and override the options they set.
+type Config struct {
+	// Dir is the directory of the configuration file. Relative paths
+	// in the configuration are resolved against it.
+	Dir      string
+	Defaults Profile
+	Profiles map[string]Profile
+	Lint     LintConfig
+}
+
+// configFile is the layout of the configuration file.
+type configFile struct {
+	Defaults Profile            `yaml:"defaults"`
+	Profiles map[string]Profile `yaml:"profiles"`
+	Lint     LintConfig         `yaml:"lint"`
+}
+
+// FindConfig returns the path of the ConfigFileName in dir or its
+// closest parent directory containing one. It returns ErrNoConfig if
+// there is none.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoConfig
+		}
+
+		dir = parent
+	}
+}
+
+// LoadConfig reads the configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	config.Dir = filepath.Dir(path)
+
+	return config, nil
+}
+
+// ParseConfig parses a configuration. Unknown options are an error.
+func ParseConfig(data []byte) (*Config, error) {
+	// decoded strictly first to report unknown options, yaml.Node.Decode
+	// does not support it
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&configFile{}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	raw := struct {
+		Defaults Profile              `yaml:"defaults"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+		Lint     LintConfig           `yaml:"lint"`
+	}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Defaults: raw.Defaults,
+		Profiles: map[string]Profile{},
+		Lint:     raw.Lint,
+	}
+
+	for name, node := range raw.Profiles {
+		// profiles are decoded over the defaults so that options not
+		// set in the profile keep their default
+		profile := raw.Defaults
+		profile.Vars = maps.Clone(raw.Defaults.Vars)
+
+		if err := node.Decode(&profile); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+
+		profile.Name = name
+		config.Profiles[name] = profile
+	}
+
+	return config, nil
+}
+
+// ProfileNames returns the names of the profiles in sorted order.
+func (config *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(config.Profiles))
+}
+
+// Profile returns the named profile or the defaults if name is empty,
+// with relative paths resolved against Dir. Exclude patterns are only
+// resolved if they contain a slash, see FindInputs.
+func (config *Config) Profile(name string) (Profile, error) {
+	profile := config.Defaults
+
+	if name != "" {
+		var ok bool
+
+		profile, ok = config.Profiles[name]
+		if !ok {
+			return Profile{}, fmt.Errorf("%w %q", ErrUnknownProfile, name)
+		}
+	}
+
+	resolve := func(path string) string {
+		if path == "" || path == "-" || filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(config.Dir, path)
+	}
+
+	inputs := make([]string, 0, len(profile.Inputs))
+	for _, input := range profile.Inputs {
+		inputs = append(inputs, resolve(input))
+	}
+
+	profile.Inputs = inputs
+
+	// exclude patterns without a slash match names at any depth
+	excludePaths := make([]string, 0, len(profile.ExcludePaths))
+	for _, pattern := range profile.ExcludePaths {
+		if strings.Contains(filepath.ToSlash(pattern), "/") {
+			pattern = resolve(pattern)
+		}
+
+		excludePaths = append(excludePaths, pattern)
+	}
+
+	profile.ExcludePaths = excludePaths
+	profile.Output = resolve(profile.Output)
+	profile.OutputDir = resolve(profile.OutputDir)
+	profile.VarsFile = resolve(profile.VarsFile)
+
+	return profile, nil
+}
+
+// Linter returns a Linter configured by the lint settings and the
+// known attributes of the defaults.
+func (config *Config) Linter() Linter {
+	return Linter{
+		Single:      Single{KnownAttributes: config.Defaults.KnownAttributes},
+		AllowedTags: config.Lint.AllowedTags,
+		Disable:     config.Lint.Disable,
+	}
+}
@@ -0,0 +1,199 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseConfig([]byte(strings.Join([]string{
+		"defaults:",
+		"  inputs: [README.md]",
+		"  tags: [sh]",
+		"  strict: true",
+		"  vars:",
+		"    A: a",
+		"profiles:",
+		"  ci:",
+		"    tags: [ci]",
+		"    output: ci.sh",
+		"    vars:",
+		"      B: b",
+		"  files:",
+		"    mode: multi",
+		"    strict: false",
+		"lint:",
+		"  allowed-tags: [ci, slow]",
+		"  disable: [indented]",
+	}, "\n")))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ci", "files"}, config.ProfileNames())
+	assert.Equal(t, Profile{
+		Inputs: []string{"README.md"},
+		Tags:   []string{"sh"},
+		Strict: true,
+		Vars:   map[string]string{"A": "a"},
+	}, config.Defaults)
+
+	assert.Equal(t, Profile{
+		Name:   "ci",
+		Inputs: []string{"README.md"},
+		Tags:   []string{"ci"},
+		Output: "ci.sh",
+		Strict: true,
+		Vars:   map[string]string{"A": "a", "B": "b"},
+	}, config.Profiles["ci"])
+
+	assert.Equal(t, Profile{
+		Name:   "files",
+		Inputs: []string{"README.md"},
+		Tags:   []string{"sh"},
+		Mode:   ModeMulti,
+		Vars:   map[string]string{"A": "a"},
+	}, config.Profiles["files"])
+
+	assert.Equal(t, LintConfig{AllowedTags: []string{"ci", "slow"}, Disable: []string{RuleIndented}}, config.Lint)
+
+	_, err = ParseConfig([]byte("profiles:\n  ci:\n    tag: [ci]\n"))
+	require.ErrorContains(t, err, "field tag not found")
+
+	config, err = ParseConfig([]byte{})
+	require.NoError(t, err)
+	assert.Empty(t, config.Profiles)
+}
+
+func TestConfig_Linter(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseConfig([]byte(strings.Join([]string{
+		"defaults:",
+		"  known-attributes: [os]",
+		"lint:",
+		"  allowed-tags: [ci]",
+		"  disable: [hidden]",
+	}, "\n")))
+	require.NoError(t, err)
+
+	linter := config.Linter()
+	assert.Equal(t, []string{"os"}, linter.KnownAttributes)
+
+	blocks, err := parseBlocks("doc.md", []byte("```sh ci os=linux\necho\n```\n\n```sh slow\necho\n```\n\n"+
+		"<!--\n```go\npackage main\n```\n-->\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Finding{
+		{Rule: RuleTag, Source: "doc.md", Line: 5, Message: `tag "slow" is not allowed`},
+	}, linter.Lint(blocks))
+}
+
+func TestConfig_Profile(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		Dir: "docs",
+		Defaults: Profile{
+			Inputs:       []string{"README.md", "-", "/abs/guide.md"},
+			ExcludePaths: []string{"drafts", "drafts/*.md"},
+			VarsFile:     "vars.env",
+		},
+		Profiles: map[string]Profile{
+			"stdout": {Name: "stdout", Output: "-"},
+		},
+	}
+
+	profile, err := config.Profile("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("docs", "README.md"), "-", "/abs/guide.md"}, profile.Inputs)
+	assert.Equal(t, []string{"drafts", filepath.Join("docs", "drafts/*.md")}, profile.ExcludePaths)
+	assert.Equal(t, filepath.Join("docs", "vars.env"), profile.VarsFile)
+
+	profile, err = config.Profile("stdout")
+	require.NoError(t, err)
+	assert.Equal(t, "-", profile.Output)
+
+	_, err = config.Profile("unknown")
+	require.ErrorIs(t, err, ErrUnknownProfile)
+}
+
+func TestProfile_Multi(t *testing.T) {
+	t.Parallel()
+
+	profile := Profile{
+		Mode:       ModeMulti,
+		FileMode:   "0755",
+		OutputDir:  "out",
+		Executable: true,
+		Tags:       []string{"ci"},
+		Filter:     "!slow",
+		Sections:   []string{"Install"},
+		Visibility: []string{"visible", "details"},
+		Template:   true,
+		Vars:       map[string]string{"NAME": "value"},
+		Strict:     true,
+	}
+
+	multi, err := profile.Multi()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(0o755), multi.FileMode)
+	assert.Equal(t, "out", multi.OutputDir)
+	assert.True(t, multi.Executable)
+	assert.Equal(t, []string{"ci"}, multi.Tags)
+	assert.Equal(t, "!slow", multi.Filter.String())
+	assert.Equal(t, "(section=Install)", multi.Section.String())
+	assert.Equal(t, []Visibility{VisibilityVisible, VisibilityDetails}, multi.Visibility)
+	assert.True(t, multi.Template.Enabled)
+	assert.Equal(t, map[string]string{"NAME": "value"}, multi.Template.Vars)
+	assert.True(t, multi.Strict)
+
+	isMulti, err := profile.IsMulti()
+	require.NoError(t, err)
+	assert.True(t, isMulti)
+
+	out, err := multi.Single.Extract([]byte("# Install\n\n```sh ci\necho ${NAME}\n```\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo value\n", out)
+
+	for _, invalid := range []Profile{
+		{Filter: "ci &&"},
+		{Visibility: []string{"hidden"}},
+		{FileMode: "rwx"},
+		{Separator: "{{ .Unclosed"},
+	} {
+		_, err := invalid.Multi()
+		require.Error(t, err, invalid)
+	}
+
+	_, err = Profile{Mode: "both"}.IsMulti()
+	require.ErrorIs(t, err, ErrUnknownMode)
+}
+
+func TestFindConfig(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	nested := filepath.Join(root, "docs", "guide")
+	require.NoError(t, os.MkdirAll(nested, 0o750))
+
+	_, err := FindConfig(nested)
+	require.ErrorIs(t, err, ErrNoConfig)
+
+	path := filepath.Join(root, ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("defaults:\n  inputs: [README.md]\n"), 0o600))
+
+	found, err := FindConfig(nested)
+	require.NoError(t, err)
+	assert.Equal(t, path, found)
+
+	config, err := LoadConfig(found)
+	require.NoError(t, err)
+	assert.Equal(t, root, config.Dir)
+}
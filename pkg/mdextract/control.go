@@ -0,0 +1,122 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownDirective is returned for unknown words in mdextract
+// directives.
+var ErrUnknownDirective = errors.New("unknown mdextract directive")
+
+// controlPrefix starts an HTML comment with mdextract directives.
+const controlPrefix = "mdextract:"
+
+// controlDefaults are tags and attributes added to the code blocks
+// following a directive until the next heading of the same or a
+// higher level.
+type controlDefaults struct {
+	level int
+	tags  []string
+	attrs map[string]string
+}
+
+// controls is the state of the mdextract directives while parsing
+// a document. It is shared with the parsers of HTML comments.
+type controls struct {
+	off      bool
+	skipNext bool
+	defaults []controlDefaults
+}
+
+// parseControl returns the directives of the content of an HTML
+// comment like "<!-- mdextract: tags=ci skip-next -->" and whether the
+// comment holds directives at all.
+func parseControl(comment []byte) ([]string, bool) {
+	words, ok := strings.CutPrefix(strings.TrimSpace(string(comment)), controlPrefix)
+	if !ok {
+		return nil, false
+	}
+
+	return splitInfo(strings.Join(strings.Fields(words), " ")), true
+}
+
+// apply applies the directives. level is the level of the heading the
+// directives are nested under, zero if there is none.
+func (c *controls) apply(directives []string, level int) error {
+	defaults := controlDefaults{level: level, attrs: map[string]string{}}
+
+	for _, directive := range directives {
+		key, value, ok := strings.Cut(directive, "=")
+
+		switch {
+		case ok && key == "tags":
+			defaults.tags = append(defaults.tags, split(unquote(value))...)
+		case ok && key != "":
+			defaults.attrs[key] = unquote(value)
+		case directive == "off":
+			c.off = true
+		case directive == "on":
+			c.off = false
+		case directive == "skip-next":
+			c.skipNext = true
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownDirective, directive)
+		}
+	}
+
+	if len(defaults.tags) > 0 || len(defaults.attrs) > 0 {
+		c.defaults = append(c.defaults, defaults)
+	}
+
+	return nil
+}
+
+// heading ends the scope of the defaults set under headings of the
+// same or a lower level.
+func (c *controls) heading(level int) {
+	c.defaults = slices.DeleteFunc(c.defaults, func(defaults controlDefaults) bool {
+		return defaults.level >= level
+	})
+}
+
+// accept reports whether the next code block is extracted and consumes
+// a skip-next directive.
+func (c *controls) accept() bool {
+	if c.off {
+		return false
+	}
+
+	if c.skipNext {
+		c.skipNext = false
+		return false
+	}
+
+	return true
+}
+
+// annotate adds the default tags and attributes to block. Tags and
+// attributes of the info string take precedence.
+func (c *controls) annotate(block *Block) {
+	for _, defaults := range c.defaults {
+		for _, tag := range defaults.tags {
+			if !slices.Contains(block.Tags, tag) {
+				block.Tags = append(block.Tags, tag)
+			}
+		}
+
+		for key, value := range defaults.attrs {
+			if _, ok := block.Attributes[key]; ok {
+				continue
+			}
+
+			if block.Attributes == nil {
+				block.Attributes = map[string]string{}
+			}
+
+			block.Attributes[key] = value
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseControl(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expected []string
+		ok       bool
+	}{
+		" mdextract: tags=ci,linux ":       {[]string{"tags=ci,linux"}, true},
+		"mdextract:skip-next":              {[]string{"skip-next"}, true},
+		"mdextract:\n  off\n  file=a.sh\n": {[]string{"off", "file=a.sh"}, true},
+		` mdextract: title="a b" `:         {[]string{`title="a b"`}, true},
+		" mdextract: ":                     {[]string{}, true},
+		" a comment ":                      {nil, false},
+		"\n```sh\nmdextract: off\n```\n":   {nil, false},
+	}
+
+	for comment, cas := range cases {
+		t.Run(comment, func(t *testing.T) {
+			t.Parallel()
+
+			directives, ok := parseControl([]byte(comment))
+			assert.Equal(t, cas.ok, ok)
+			assert.Equal(t, cas.expected, directives)
+		})
+	}
+}
+
+func TestBlocks_Controls(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"<!-- mdextract: tags=docs -->",
+		"",
+		"# Install",
+		"",
+		"<!-- mdextract: tags=ci,linux file=setup.sh -->",
+		"",
+		"```sh",
+		"apt install mdextract",
+		"```",
+		"",
+		"## Arch",
+		"",
+		"```sh linux file=arch.sh",
+		"pacman -S mdextract",
+		"```",
+		"",
+		"<!-- mdextract: skip-next -->",
+		"",
+		"```sh",
+		"rm -rf /",
+		"```",
+		"",
+		"<!--",
+		"```sh",
+		"echo hidden",
+		"```",
+		"-->",
+		"",
+		"# Usage",
+		"",
+		"<!-- mdextract: off -->",
+		"",
+		"```sh",
+		"mdextract --help",
+		"```",
+		"",
+		"<!-- mdextract: on -->",
+		"",
+		"```sh",
+		"mdextract -output - README.md",
+		"```",
+		"",
+	}, "\n")
+
+	blocks, err := parseBlocks("doc.md", []byte(input))
+	require.NoError(t, err)
+
+	type summary struct {
+		content string
+		tags    []string
+		attrs   map[string]string
+		line    int
+	}
+
+	actual := []summary{}
+	for _, block := range blocks {
+		actual = append(actual, summary{block.Content, block.Tags, block.Attributes, block.StartLine})
+	}
+
+	assert.Equal(t, []summary{
+		{"apt install mdextract\n", []string{"sh", "docs", "ci", "linux"}, map[string]string{"file": "setup.sh"}, 7},
+		{"pacman -S mdextract\n", []string{"sh", "linux", "docs", "ci"}, map[string]string{"file": "arch.sh"}, 13},
+		{"echo hidden\n", []string{"sh", "docs", "ci", "linux"}, map[string]string{"file": "setup.sh"}, 24},
+		{"mdextract -output - README.md\n", []string{"sh", "docs"}, map[string]string{}, 39},
+	}, actual)
+}
+
+func TestBlocks_Controls_Unknown(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseBlocks("doc.md", []byte("# Title\n\n<!-- mdextract: skip -->\n"))
+	require.ErrorIs(t, err, ErrUnknownDirective)
+	require.ErrorContains(t, err, "doc.md:3:")
+}
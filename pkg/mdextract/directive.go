@@ -0,0 +1,124 @@
+package mdextract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commentStyle describes how a language refers back to a position in
+// the markdown source.
+type commentStyle int
+
+const (
+	commentNone commentStyle = iota
+	// commentGo uses the //line compiler directive.
+	commentGo
+	// commentC uses the #line preprocessor directive.
+	commentC
+	// commentHash uses a # comment.
+	commentHash
+	// commentSlash uses a // comment.
+	commentSlash
+	// commentDash uses a -- comment.
+	commentDash
+)
+
+var languageComments = map[string]commentStyle{
+	"go":     commentGo,
+	"golang": commentGo,
+
+	"c":    commentC,
+	"h":    commentC,
+	"cc":   commentC,
+	"cpp":  commentC,
+	"c++":  commentC,
+	"cxx":  commentC,
+	"hpp":  commentC,
+	"objc": commentC,
+
+	"sh":         commentHash,
+	"bash":       commentHash,
+	"zsh":        commentHash,
+	"ksh":        commentHash,
+	"fish":       commentHash,
+	"shell":      commentHash,
+	"python":     commentHash,
+	"python3":    commentHash,
+	"py":         commentHash,
+	"ruby":       commentHash,
+	"rb":         commentHash,
+	"perl":       commentHash,
+	"pl":         commentHash,
+	"r":          commentHash,
+	"yaml":       commentHash,
+	"yml":        commentHash,
+	"toml":       commentHash,
+	"dockerfile": commentHash,
+	"make":       commentHash,
+	"makefile":   commentHash,
+	"powershell": commentHash,
+	"ps1":        commentHash,
+	"nix":        commentHash,
+	"hcl":        commentHash,
+	"terraform":  commentHash,
+	"tf":         commentHash,
+
+	"js":         commentSlash,
+	"javascript": commentSlash,
+	"jsx":        commentSlash,
+	"ts":         commentSlash,
+	"typescript": commentSlash,
+	"tsx":        commentSlash,
+	"java":       commentSlash,
+	"kotlin":     commentSlash,
+	"kt":         commentSlash,
+	"scala":      commentSlash,
+	"swift":      commentSlash,
+	"rust":       commentSlash,
+	"rs":         commentSlash,
+	"cs":         commentSlash,
+	"csharp":     commentSlash,
+	"dart":       commentSlash,
+	"php":        commentSlash,
+	"jsonc":      commentSlash,
+	"zig":        commentSlash,
+
+	"sql":     commentDash,
+	"lua":     commentDash,
+	"haskell": commentDash,
+	"hs":      commentDash,
+}
+
+func commentStyleOf(lang string) commentStyle {
+	return languageComments[strings.ToLower(lang)]
+}
+
+// contentLine returns the line of the first line of content.
+func (block Block) contentLine() int {
+	if block.FenceChar == 0 {
+		return block.StartLine
+	}
+
+	return block.StartLine + 1
+}
+
+// lineDirective returns a marker for the given language pointing at
+// line in source. It returns an empty string if the language is
+// unknown or has no comment syntax.
+func lineDirective(lang, source string, line int) string {
+	switch commentStyleOf(lang) {
+	case commentGo:
+		return fmt.Sprintf("//line %s:%d\n", source, line)
+	case commentC:
+		return fmt.Sprintf("#line %d %q\n", line, source)
+	case commentHash:
+		return fmt.Sprintf("# %s:%d\n", source, line)
+	case commentSlash:
+		return fmt.Sprintf("// %s:%d\n", source, line)
+	case commentDash:
+		return fmt.Sprintf("-- %s:%d\n", source, line)
+	case commentNone:
+	}
+
+	return ""
+}
@@ -0,0 +1,104 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineDirective(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		lang     string
+		expected string
+	}{
+		"go":      {"go", "//line README.md:42\n"},
+		"c":       {"c", "#line 42 \"README.md\"\n"},
+		"bash":    {"bash", "# README.md:42\n"},
+		"python":  {"Python", "# README.md:42\n"},
+		"yaml":    {"yaml", "# README.md:42\n"},
+		"js":      {"js", "// README.md:42\n"},
+		"sql":     {"sql", "-- README.md:42\n"},
+		"json":    {"json", ""},
+		"unknown": {"unknown", ""},
+		"empty":   {"", ""},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, cas.expected, lineDirective(cas.lang, "README.md", 42))
+		})
+	}
+}
+
+func TestSingle_Extract_LineDirectives(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"# title",
+		"",
+		"```go",
+		"package main",
+		"```",
+		"",
+		"```bash",
+		"#!/bin/sh",
+		"echo ok",
+		"```",
+		"",
+		"```json",
+		"{}",
+		"```",
+		"",
+		"    indented",
+	}, "\n")
+
+	blocks, err := parseBlocks("README.md", []byte(input))
+	require.NoError(t, err)
+
+	single := Single{LineDirectives: true}
+	extracted, err := single.extract(blocks)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"//line README.md:4",
+		"package main",
+		"#!/bin/sh",
+		"# README.md:9",
+		"echo ok",
+		"{}",
+		"indented",
+		"",
+	}, "\n"), extracted)
+}
+
+func TestMulti_Extract_LineDirectives(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```go file=main.go",
+		"package main",
+		"```",
+		"```yaml file=config.yaml",
+		"a: b",
+		"```",
+		"```go file=main.go",
+		"func main() {}",
+		"```",
+	}, "\n")
+
+	blocks, err := parseBlocks("README.md", []byte(input))
+	require.NoError(t, err)
+
+	multi := &Multi{Single: Single{LineDirectives: true}}
+	extracted, err := multi.extract(blocks)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"main.go":     "//line README.md:2\npackage main\n//line README.md:8\nfunc main() {}\n",
+		"config.yaml": "# README.md:5\na: b\n",
+	}, extracted)
+}
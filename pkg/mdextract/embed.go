@@ -0,0 +1,204 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/parser"
+)
+
+var (
+	// ErrRegionNotFound is returned if a region referenced by
+	// a "region" attribute does not exist in the source file.
+	ErrRegionNotFound = errors.New("region not found")
+	// ErrFenceInContent is returned if embedded content contains
+	// a line that would close the code block.
+	ErrFenceInContent = errors.New("content contains the closing fence of the code block")
+	// ErrSourceEscapes is returned for "source" attributes pointing
+	// outside of the directory of the markdown file.
+	ErrSourceEscapes = errors.New("source escapes the directory of the markdown file")
+)
+
+var (
+	regionStart = regexp.MustCompile(`^\s*(?://|#|--|/\*|<!--)\s*#?region\s+([\w.-]+)\s*(?:\*/|-->)?\s*$`)
+	regionEnd   = regexp.MustCompile(`^\s*(?://|#|--|/\*|<!--)\s*#?endregion(?:\s+([\w.-]+))?\s*(?:\*/|-->)?\s*$`)
+)
+
+// extractRegion returns the lines between the "region name" and
+// "endregion" marker comments in content, e.g.:
+//
+//	// region setup
+//	...
+//	// endregion setup
+//
+// Markers are lines consisting only of a comment starting with //, #,
+// --, /* or <!--. Marker lines of other regions inside the region are
+// removed.
+func extractRegion(content, name string) (string, error) {
+	builder := &strings.Builder{}
+	found, inside := false, false
+
+	for line := range strings.Lines(content) {
+		if m := regionEnd.FindStringSubmatch(line); m != nil {
+			if inside && (m[1] == "" || m[1] == name) {
+				return builder.String(), nil
+			}
+
+			continue
+		}
+
+		if m := regionStart.FindStringSubmatch(line); m != nil {
+			if m[1] == name {
+				found, inside = true, true
+			}
+
+			continue
+		}
+
+		if inside {
+			builder.WriteString(line)
+		}
+	}
+
+	if found {
+		return "", fmt.Errorf("region %q: missing endregion: %w", name, ErrRegionNotFound)
+	}
+
+	return "", fmt.Errorf("region %q: %w", name, ErrRegionNotFound)
+}
+
+// embedContent returns the content the block should have according to
+// its "source" and "region" attributes. Sources are resolved relative
+// to the directory of the markdown file and must not point outside of
+// it.
+func embedContent(markdownPath string, block Block) (string, error) {
+	name := filepath.FromSlash(block.Attributes["source"])
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("source %q: %w", block.Attributes["source"], ErrSourceEscapes)
+	}
+
+	root, err := os.OpenRoot(filepath.Dir(markdownPath))
+	if err != nil {
+		return "", err
+	}
+	defer root.Close() //nolint:errcheck
+
+	source := filepath.Join(filepath.Dir(markdownPath), name)
+
+	data, err := root.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	content := string(data)
+
+	if region, ok := block.Attributes["region"]; ok {
+		content, err = extractRegion(content, region)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", source, err)
+		}
+	}
+
+	return withNewline(content), nil
+}
+
+// withNewline adds a trailing newline to s unless it is empty or
+// already ends with one.
+func withNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+
+	return s
+}
+
+// Embed replaces the content of fenced code blocks that have a "source"
+// attribute with the contents of the referenced file and returns the
+// updated markdown. If the block also has a "region" attribute only the
+// lines between the region markers are embedded, see extractRegion.
+//
+// path is the path of the markdown file, sources are resolved relative
+// to its directory. Absolute sources, sources escaping the directory
+// with ".." and sources through symlinks pointing outside of it are
+// rejected. Only code blocks matching the criteria are updated,
+// everything else including the info strings is left untouched.
+func (single Single) Embed(path string, data []byte) ([]byte, error) {
+	blocks, err := parseBlocks(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.SplitAfter(string(parser.NormalizeNewlines(data)), "\n")
+
+	selected := single.Select(blocks)
+	for i := len(selected) - 1; i >= 0; i-- {
+		block := selected[i]
+		if block.Attributes["source"] == "" || block.FenceChar == 0 {
+			continue
+		}
+
+		content, err := embedContent(path, block)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, block.StartLine, err)
+		}
+
+		lines, err = replaceBlockContent(lines, block, content)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, block.StartLine, err)
+		}
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// replaceBlockContent replaces the lines between the fences of block
+// with content. The indentation or blockquote prefix of the opening
+// fence is added to each line. A missing trailing newline is added so
+// the closing fence stays on its own line.
+func replaceBlockContent(lines []string, block Block, content string) ([]string, error) {
+	content = withNewline(content)
+
+	opening := lines[block.StartLine-1]
+	prefix := opening[:len(opening)-len(stripPrefix([]byte(opening)))]
+	marker, _, _ := parseFenceLine([]byte(opening))
+
+	replacement := []string{}
+
+	for line := range strings.Lines(content) {
+		if strings.HasPrefix(strings.TrimSpace(line), marker) {
+			return nil, ErrFenceInContent
+		}
+
+		if strings.TrimSpace(line) == "" {
+			replacement = append(replacement, strings.TrimRight(prefix, " \t")+line)
+			continue
+		}
+
+		replacement = append(replacement, prefix+line)
+	}
+
+	ret := append([]string{}, lines[:block.StartLine]...)
+	ret = append(ret, replacement...)
+
+	return append(ret, lines[block.EndLine-1:]...), nil
+}
+
+// EmbedFile embeds source files into the markdown file at path, see
+// Embed. It returns the current and the updated contents of the file.
+func (single Single) EmbedFile(path string) ([]byte, []byte, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, err := single.Embed(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, updated, nil
+}
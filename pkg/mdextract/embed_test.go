@@ -0,0 +1,246 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractRegion(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Join([]string{
+		"package main",
+		"",
+		"// region setup",
+		"import \"fmt\"",
+		"// pick a region eu-west-1 close to the users",
+		"// endregion setup",
+		"",
+		"func main() {",
+		"\t// region body",
+		"\tfmt.Println(\"hi\")",
+		"\t// region inner",
+		"\tfmt.Println(\"inner\")",
+		"\t// endregion inner",
+		"\t// endregion",
+		"}",
+		"/* region style */",
+		"a { color: red; }",
+		"/* endregion */",
+		"<!-- #region html -->",
+		"<p>region</p>",
+		"<!-- #endregion -->",
+		"# region unterminated",
+		"",
+	}, "\n")
+
+	cases := map[string]struct {
+		region   string
+		expected string
+		err      bool
+	}{
+		"setup":        {"setup", "import \"fmt\"\n// pick a region eu-west-1 close to the users\n", false},
+		"style":        {"style", "a { color: red; }\n", false},
+		"html":         {"html", "<p>region</p>\n", false},
+		"body":         {"body", "\tfmt.Println(\"hi\")\n\tfmt.Println(\"inner\")\n", false},
+		"inner":        {"inner", "\tfmt.Println(\"inner\")\n", false},
+		"missing":      {"missing", "", true},
+		"unterminated": {"unterminated", "", true},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := extractRegion(content, cas.region)
+			if cas.err {
+				require.ErrorIs(t, err, ErrRegionNotFound)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, result)
+		})
+	}
+}
+
+func TestSingle_Embed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"examples/main.go":  "package main\n\n// region body\nfunc main() {}\n// endregion\n",
+		"examples/run.sh":   "echo ok",
+		"examples/fence.md": "```\n",
+	})
+
+	cases := map[string]struct {
+		single   Single
+		input    []string
+		expected []string
+		err      error
+	}{
+		"whole file": {
+			input: []string{
+				"```go source=examples/main.go ci",
+				"old",
+				"```",
+			},
+			expected: []string{
+				"```go source=examples/main.go ci",
+				"package main",
+				"",
+				"// region body",
+				"func main() {}",
+				"// endregion",
+				"```",
+			},
+		},
+		"region in blockquote": {
+			input: []string{
+				"text",
+				"",
+				"> ```go source=examples/main.go region=body",
+				"> ```",
+				"",
+				"```go",
+				"untouched",
+				"```",
+			},
+			expected: []string{
+				"text",
+				"",
+				"> ```go source=examples/main.go region=body",
+				"> func main() {}",
+				"> ```",
+				"",
+				"```go",
+				"untouched",
+				"```",
+			},
+		},
+		"missing newline": {
+			input: []string{
+				"~~~sh source=examples/run.sh",
+				"~~~",
+			},
+			expected: []string{
+				"~~~sh source=examples/run.sh",
+				"echo ok",
+				"~~~",
+			},
+		},
+		"filtered": {
+			single: Single{Tags: []string{"ci"}},
+			input: []string{
+				"```sh source=examples/run.sh",
+				"old",
+				"```",
+			},
+			expected: []string{
+				"```sh source=examples/run.sh",
+				"old",
+				"```",
+			},
+		},
+		"in comment": {
+			input: []string{
+				"<!--",
+				"```sh source=examples/run.sh",
+				"```",
+				"-->",
+			},
+			expected: []string{
+				"<!--",
+				"```sh source=examples/run.sh",
+				"echo ok",
+				"```",
+				"-->",
+			},
+		},
+		"missing region": {
+			input: []string{
+				"```go source=examples/main.go region=missing",
+				"```",
+			},
+			err: ErrRegionNotFound,
+		},
+		"fence in content": {
+			input: []string{
+				"```md source=examples/fence.md",
+				"```",
+			},
+			err: ErrFenceInContent,
+		},
+		"missing source": {
+			input: []string{
+				"```md source=examples/missing.md",
+				"```",
+			},
+			err: os.ErrNotExist,
+		},
+		"absolute source": {
+			input: []string{
+				"```sh source=/etc/passwd",
+				"```",
+			},
+			err: ErrSourceEscapes,
+		},
+		"escaping source": {
+			input: []string{
+				"```sh source=../../etc/passwd",
+				"```",
+			},
+			err: ErrSourceEscapes,
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			input := strings.Join(cas.input, "\n") + "\n"
+
+			result, err := cas.single.Embed(filepath.Join(dir, "README.md"), []byte(input))
+			if cas.err != nil {
+				require.ErrorIs(t, err, cas.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, strings.Join(cas.expected, "\n")+"\n", string(result))
+		})
+	}
+}
+
+func TestSingle_Embed_SymlinkOutside(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(outside, []byte("secret\n"), 0o600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+	_, err := Single{}.Embed(filepath.Join(dir, "README.md"), []byte("```sh source=link\n```\n"))
+	require.Error(t, err)
+}
+
+func TestSingle_EmbedFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"run.sh":    "echo ok\n",
+		"README.md": "```sh source=run.sh\n```\n",
+	})
+
+	current, updated, err := Single{}.EmbedFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "```sh source=run.sh\n```\n", string(current))
+	assert.Equal(t, "```sh source=run.sh\necho ok\n```\n", string(updated))
+}
@@ -0,0 +1,340 @@
+package mdextract
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// OutputTag marks a code block as the expected output of the code
+// block preceding it.
+const OutputTag = "output"
+
+// MatchMode controls how the expected output of a code block is compared
+// with its actual output.
+type MatchMode string
+
+const (
+	// MatchExact requires the outputs to be equal.
+	MatchExact MatchMode = "exact"
+	// MatchEllipsis treats "..." in the expected output as a wildcard.
+	// A line consisting only of "..." matches any number of lines,
+	// elsewhere it matches any text within a line.
+	MatchEllipsis MatchMode = "ellipsis"
+	// MatchRegex treats the expected output as a regular expression
+	// that must match the whole output.
+	MatchRegex MatchMode = "regex"
+)
+
+var (
+	// ErrOutputMismatch is returned for code blocks whose output does
+	// not match the expected output.
+	ErrOutputMismatch = errors.New("output does not match the expected output")
+	// ErrUnknownMatchMode is returned for unknown match modes.
+	ErrUnknownMatchMode = errors.New("unknown match mode")
+)
+
+// ParseMatchMode parses the name of a match mode. An empty string is
+// MatchExact.
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch mode := MatchMode(s); mode {
+	case "":
+		return MatchExact, nil
+	case MatchExact, MatchEllipsis, MatchRegex:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownMatchMode, s)
+	}
+}
+
+// Expectation is the expected output of a code block.
+type Expectation struct {
+	// Block is the code block tagged "output" that holds the expected
+	// output. It is nil if the output is given by an "expect"
+	// attribute.
+	Block *Block
+	// Content is the expected output.
+	Content string
+	// Match is the match mode set by a "match" attribute, empty if the
+	// default of the Runner applies.
+	Match MatchMode
+	// Trim is true if a "trim" attribute is set.
+	Trim bool
+}
+
+// isOutput reports whether the block holds the expected output of the
+// preceding code block.
+func (block Block) isOutput() bool {
+	return slices.Contains(block.Tags, OutputTag)
+}
+
+// expectation returns the expected output of the code block at index
+// i in blocks. The expected output is either given in the "expect"
+// attribute of the code block or by the code block following it if
+// that is tagged "output".
+func expectation(blocks []Block, i int) *Expectation {
+	block := blocks[i]
+
+	if expect, ok := block.Attributes["expect"]; ok {
+		return newExpectation(nil, expect+"\n", block.Attributes)
+	}
+
+	if i+1 >= len(blocks) {
+		return nil
+	}
+
+	next := blocks[i+1]
+	if !next.isOutput() || next.Source != block.Source {
+		return nil
+	}
+
+	return newExpectation(&next, next.Content, next.Attributes)
+}
+
+func newExpectation(block *Block, content string, attrs map[string]string) *Expectation {
+	_, trim := attrs["trim"]
+
+	return &Expectation{
+		Block:   block,
+		Content: content,
+		Match:   MatchMode(attrs["match"]),
+		Trim:    trim && attrs["trim"] != "false",
+	}
+}
+
+// SelectWithOutputs returns the blocks matching the criteria and the
+// blocks they require like Resolve, with references expanded and
+// variables substituted. Blocks tagged "output" directly following
+// a returned code block are included as is, regardless of the criteria.
+func (single Single) SelectWithOutputs(blocks []Block) ([]Block, error) {
+	order, err := resolve(blocks, single.selected(blocks), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []Block{}
+	outputs := map[int]Block{}
+
+	for _, i := range order {
+		if blocks[i].isOutput() {
+			continue
+		}
+
+		if i+1 < len(blocks) && blocks[i+1].isOutput() {
+			outputs[len(selected)] = blocks[i+1]
+		}
+
+		selected = append(selected, blocks[i])
+	}
+
+	processed, err := single.process(blocks, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Block{}
+
+	// processing keeps the order and only drops referenced blocks, so
+	// the outputs are matched by walking both in order
+	j := 0
+
+	for _, block := range processed {
+		for j < len(selected) && !sameBlock(selected[j], block) {
+			j++
+		}
+
+		ret = append(ret, block)
+
+		if output, ok := outputs[j]; ok {
+			ret = append(ret, output)
+		}
+
+		j++
+	}
+
+	return ret, nil
+}
+
+// sameBlock reports whether a and b are the same code block, regardless
+// of their contents.
+func sameBlock(a, b Block) bool {
+	return a.Source == b.Source && a.StartLine == b.StartLine && a.Name() == b.Name() && slices.Equal(a.Tags, b.Tags)
+}
+
+// trimOutput removes leading and trailing blank lines and trailing
+// whitespace on each line.
+func trimOutput(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ellipsisPattern converts expected output with "..." wildcards into
+// a regular expression.
+func ellipsisPattern(expected string) string {
+	builder := &strings.Builder{}
+
+	for line := range strings.Lines(expected) {
+		if strings.TrimSpace(line) == "..." {
+			builder.WriteString(`(?:.*\n?)*?`)
+			continue
+		}
+
+		parts := strings.Split(line, "...")
+		for i, part := range parts {
+			if i > 0 {
+				builder.WriteString(`.*?`)
+			}
+
+			builder.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+
+	return builder.String()
+}
+
+// Matches reports whether actual matches the expected output using the
+// given default match mode and trimming. The "match" and "trim"
+// attributes of the expectation take precedence.
+//
+// A missing trailing newline is ignored, as output blocks and "expect"
+// attributes always end with one.
+func (expected Expectation) Matches(actual string, mode MatchMode, trim bool) (bool, error) {
+	if expected.Match != "" {
+		mode = expected.Match
+	}
+
+	content, actual := withNewline(expected.Content), withNewline(actual)
+	if trim || expected.Trim {
+		content, actual = trimOutput(content), trimOutput(actual)
+	}
+
+	switch mode {
+	case "", MatchExact:
+		return content == actual, nil
+	case MatchEllipsis:
+		return regexp.MustCompile(`^` + ellipsisPattern(content) + `$`).MatchString(actual), nil
+	case MatchRegex:
+		re, err := regexp.Compile(`^(?:` + strings.TrimSuffix(content, "\n") + `)\n?$`)
+		if err != nil {
+			return false, err
+		}
+
+		return re.MatchString(actual), nil
+	default:
+		return false, fmt.Errorf("%w %q", ErrUnknownMatchMode, mode)
+	}
+}
+
+// UpdateExpected replaces the expected outputs in the markdown data
+// with the actual outputs of the results whose output did not match.
+// path is the name of the markdown file, results from other files are
+// ignored.
+//
+// Output blocks are replaced with the actual output. "expect"
+// attributes are only updated if the actual output is a single line.
+func UpdateExpected(path string, data []byte, results []Result) ([]byte, error) {
+	lines := strings.SplitAfter(string(parser.NormalizeNewlines(data)), "\n")
+
+	// update from the end of the document so the line numbers of
+	// earlier blocks stay valid, results are in execution order which
+	// differs with "requires" attributes
+	results = slices.Clone(results)
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Compare(b.Block.StartLine, a.Block.StartLine)
+	})
+
+	for _, result := range results {
+		if result.Block.Source != path || result.Expected == nil || !errors.Is(result.Err, ErrOutputMismatch) {
+			continue
+		}
+
+		var err error
+
+		if result.Expected.Block != nil {
+			lines, err = replaceBlockContent(lines, *result.Expected.Block, result.Stdout)
+		} else {
+			lines, err = replaceExpectAttribute(lines, result.Block, result.Stdout)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, result.Block.StartLine, err)
+		}
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// replaceExpectAttribute replaces the value of the "expect" attribute
+// in the opening fence of block.
+func replaceExpectAttribute(lines []string, block Block, output string) ([]string, error) {
+	output = strings.TrimSuffix(output, "\n")
+	if strings.Contains(output, "\n") {
+		return nil, errors.New("cannot update expect attribute with multiple lines of output")
+	}
+
+	quoted := `"` + output + `"`
+	if strings.Contains(output, `"`) {
+		if strings.Contains(output, "'") {
+			return nil, errors.New("cannot update expect attribute with output containing both quote characters")
+		}
+
+		quoted = "'" + output + "'"
+	}
+
+	for _, word := range splitInfo(block.Info) {
+		// braced info strings separate attributes with commas
+		word = strings.TrimRight(word, ",}")
+		if !strings.HasPrefix(word, "expect=") {
+			continue
+		}
+
+		line := lines[block.StartLine-1]
+		lines[block.StartLine-1] = strings.Replace(line, word, "expect="+quoted, 1)
+
+		return lines, nil
+	}
+
+	return nil, errors.New("expect attribute not found in the info string")
+}
+
+// check compares the stdout of the result to the expected output and
+// sets Err on mismatches, including a diff of the outputs.
+func (runner Runner) check(result *Result) {
+	if result.Err != nil || result.Expected == nil {
+		return
+	}
+
+	ok, err := result.Expected.Matches(result.Stdout, runner.Match, runner.Trim)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	if ok {
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(result.Expected.Content),
+		B:        splitLines(result.Stdout),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  diffContext,
+	})
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	result.Err = fmt.Errorf("%w\n%s", ErrOutputMismatch, strings.TrimSuffix(diff, "\n"))
+}
@@ -0,0 +1,273 @@
+package mdextract
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectation_Matches(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expected Expectation
+		actual   string
+		mode     MatchMode
+		trim     bool
+		match    bool
+	}{
+		"exact": {
+			expected: Expectation{Content: "hello\n"},
+			actual:   "hello\n",
+			match:    true,
+		},
+		"exact without trailing newline": {
+			expected: Expectation{Content: "hello\n"},
+			actual:   "hello",
+			match:    true,
+		},
+		"exact mismatch": {
+			expected: Expectation{Content: "hello\n"},
+			actual:   "hello \n",
+		},
+		"trim": {
+			expected: Expectation{Content: "\nhello\nworld\n\n"},
+			actual:   "hello   \nworld",
+			trim:     true,
+			match:    true,
+		},
+		"trim attribute": {
+			expected: Expectation{Content: "hello\n", Trim: true},
+			actual:   "hello",
+			match:    true,
+		},
+		"ellipsis in line": {
+			expected: Expectation{Content: "took ...ms\n"},
+			actual:   "took 42ms\n",
+			mode:     MatchEllipsis,
+			match:    true,
+		},
+		"ellipsis does not span lines": {
+			expected: Expectation{Content: "a...b\n"},
+			actual:   "a\nb\n",
+			mode:     MatchEllipsis,
+		},
+		"ellipsis lines": {
+			expected: Expectation{Content: "start\n...\nend\n"},
+			actual:   "start\none\ntwo\nend\n",
+			mode:     MatchEllipsis,
+			match:    true,
+		},
+		"ellipsis zero lines": {
+			expected: Expectation{Content: "start\n...\nend\n"},
+			actual:   "start\nend\n",
+			mode:     MatchEllipsis,
+			match:    true,
+		},
+		"ellipsis escapes regex": {
+			expected: Expectation{Content: "a.b\n"},
+			actual:   "axb\n",
+			mode:     MatchEllipsis,
+		},
+		"regex": {
+			expected: Expectation{Content: "version v[0-9]+\\.[0-9]+\n"},
+			actual:   "version v1.22\n",
+			mode:     MatchRegex,
+			match:    true,
+		},
+		"regex is anchored": {
+			expected: Expectation{Content: "v[0-9]+\n"},
+			actual:   "version v1\n",
+			mode:     MatchRegex,
+		},
+		"match attribute overrides": {
+			expected: Expectation{Content: "a...\n", Match: MatchEllipsis},
+			actual:   "abc\n",
+			mode:     MatchExact,
+			match:    true,
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			match, err := tc.expected.Matches(tc.actual, tc.mode, tc.trim)
+			require.NoError(t, err)
+			assert.Equal(t, tc.match, match)
+		})
+	}
+}
+
+func TestExpectation_Matches_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := Expectation{Content: "(", Match: MatchRegex}.Matches("", MatchExact, false)
+	require.Error(t, err)
+
+	_, err = Expectation{Content: "", Match: "fuzzy"}.Matches("", MatchExact, false)
+	require.ErrorIs(t, err, ErrUnknownMatchMode)
+}
+
+func TestRunner_Run_Expected(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := Blocks([]byte("```sh\necho hello\n```\n\n```output\nhello\n```\n\n" +
+		"```sh\necho world\n```\n\n```text output\nhello\n```\n\n" +
+		"```sh expect=hello\necho hello\n```\n"))
+	require.NoError(t, err)
+
+	results := slices.Collect(Runner{}.Run(t.Context(), blocks))
+	require.Len(t, results, 3)
+
+	require.NoError(t, results[0].Err)
+	require.NotNil(t, results[0].Expected)
+	assert.Equal(t, 5, results[0].Expected.Block.StartLine)
+
+	require.ErrorIs(t, results[1].Err, ErrOutputMismatch)
+	assert.Contains(t, results[1].Err.Error(), "-hello\n+world")
+
+	require.NoError(t, results[2].Err)
+	assert.Nil(t, results[2].Expected.Block)
+}
+
+func TestSingle_SelectWithOutputs(t *testing.T) {
+	t.Parallel()
+
+	blocks := []Block{
+		{Content: "1", Tags: []string{"sh"}},
+		{Content: "2", Tags: []string{"output"}},
+		{Content: "3", Tags: []string{"python"}},
+		{Content: "4", Tags: []string{"output"}},
+		{Content: "5", Tags: []string{"sh"}},
+	}
+
+	selected, err := Single{Tags: []string{"sh"}}.SelectWithOutputs(blocks)
+	require.NoError(t, err)
+
+	contents := []string{}
+	for _, block := range selected {
+		contents = append(contents, block.Content)
+	}
+
+	assert.Equal(t, []string{"1", "2", "5"}, contents)
+}
+
+func TestSingle_SelectWithOutputs_Process(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Join([]string{
+		"```sh",
+		"echo ${GREETING}",
+		"<<suffix>>",
+		"```",
+		"",
+		"```output",
+		"hello",
+		"```",
+		"",
+		"```sh name=suffix",
+		"echo world",
+		"```",
+		"",
+		"```sh",
+		"echo last",
+		"```",
+		"",
+		"```output",
+		"last",
+		"```",
+	}, "\n")
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(data))
+	require.NoError(t, err)
+
+	single := Single{
+		ExpandReferences: true,
+		Template:         Template{Enabled: true, Vars: map[string]string{"GREETING": "hello"}},
+	}
+	selected, err := single.SelectWithOutputs(blocks)
+	require.NoError(t, err)
+
+	contents := []string{}
+	for _, block := range selected {
+		contents = append(contents, block.Content)
+	}
+
+	assert.Equal(t, []string{"echo hello\necho world\n", "hello\n", "echo last\n", "last\n"}, contents)
+}
+
+func TestUpdateExpected(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("```sh expect=hello\necho world\n```\n\n" +
+		"```sh\necho same\n```\n\n```output\nsame\n```\n\n" +
+		"```sh\necho world\n```\n\n> ```output\n> hello\n> ```\n")
+
+	blocks, err := BlocksFromReader("doc.md", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	results := slices.Collect(Runner{}.Run(t.Context(), blocks))
+
+	updated, err := UpdateExpected("doc.md", data, results)
+	require.NoError(t, err)
+	assert.Equal(t, "```sh expect=\"world\"\necho world\n```\n\n"+
+		"```sh\necho same\n```\n\n```output\nsame\n```\n\n"+
+		"```sh\necho world\n```\n\n> ```output\n> world\n> ```\n", string(updated))
+}
+
+func TestUpdateExpected_NoTrailingNewline(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("```sh\nprintf hi\n```\n\n```output\nhello\n```\n")
+
+	blocks, err := BlocksFromReader("doc.md", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	results := slices.Collect(Runner{}.Run(t.Context(), blocks))
+
+	updated, err := UpdateExpected("doc.md", data, results)
+	require.NoError(t, err)
+	assert.Equal(t, "```sh\nprintf hi\n```\n\n```output\nhi\n```\n", string(updated))
+
+	blocks, err = BlocksFromReader("doc.md", bytes.NewReader(updated))
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "hi\n", blocks[1].Content)
+
+	// the updated output matches, so updating again changes nothing
+	results = slices.Collect(Runner{}.Run(t.Context(), blocks))
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+
+	again, err := UpdateExpected("doc.md", updated, results)
+	require.NoError(t, err)
+	assert.Equal(t, string(updated), string(again))
+}
+
+func TestUpdateExpected_Requires(t *testing.T) {
+	t.Parallel()
+
+	// the first block requires the second one, so it is executed last
+	data := []byte("```sh requires=second\nprintf 'one\\ntwo\\n'\n```\n\n```output\n```\n\n" +
+		"```sh name=second\necho second\n```\n\n```output\nold\n```\n")
+
+	all, err := BlocksFromReader("doc.md", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	blocks, err := Single{}.SelectWithOutputs(all)
+	require.NoError(t, err)
+
+	results := slices.Collect(Runner{}.Run(t.Context(), blocks))
+	require.Len(t, results, 2)
+	assert.Equal(t, "second", results[0].Block.Name())
+
+	updated, err := UpdateExpected("doc.md", data, results)
+	require.NoError(t, err)
+	assert.Equal(t, "```sh requires=second\nprintf 'one\\ntwo\\n'\n```\n\n```output\none\ntwo\n```\n\n"+
+		"```sh name=second\necho second\n```\n\n```output\nsecond\n```\n", string(updated))
+}
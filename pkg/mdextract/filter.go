@@ -0,0 +1,422 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Filter is a boolean expression that is evaluated against code
+// blocks.
+//
+// Filters are parsed from expressions like:
+//
+//	bash && (ci || smoke) && !slow
+//	go || golang
+//	file=*.go and not noci
+//
+// A bare word matches blocks that have the word as a tag.
+// key=value matches blocks whose attribute key matches the glob
+// pattern value, key!=value is the negation. section=pattern matches
+// blocks nested under a heading, see ParseSection. Expressions can be
+// combined with && (and), || (or), ! (not) and parentheses.
+type Filter interface {
+	// Match reports whether the block matches the filter.
+	Match(block Block) bool
+	// String returns the filter as an expression.
+	String() string
+}
+
+type filterAll struct{}
+
+func (filterAll) Match(Block) bool { return true }
+func (filterAll) String() string   { return "" }
+
+type filterTag struct {
+	tag string
+}
+
+func (f filterTag) Match(block Block) bool {
+	return slices.Contains(block.Tags, f.tag)
+}
+
+func (f filterTag) String() string {
+	return quoteFilterWord(f.tag)
+}
+
+type filterAttr struct {
+	key     string
+	pattern string
+}
+
+func (f filterAttr) Match(block Block) bool {
+	value, ok := block.Attributes[f.key]
+	if !ok {
+		return false
+	}
+
+	matched, err := path.Match(f.pattern, value)
+	if err != nil {
+		// invalid patterns are compared literally
+		return f.pattern == value
+	}
+
+	return matched
+}
+
+func (f filterAttr) String() string {
+	return quoteFilterWord(f.key) + "=" + quoteFilterWord(f.pattern)
+}
+
+type filterNot struct {
+	filter Filter
+}
+
+func (f filterNot) Match(block Block) bool {
+	return !f.filter.Match(block)
+}
+
+func (f filterNot) String() string {
+	return "!" + f.filter.String()
+}
+
+type filterAnd []Filter
+
+func (f filterAnd) Match(block Block) bool {
+	for _, filter := range f {
+		if !filter.Match(block) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f filterAnd) String() string {
+	return joinFilters(f, " && ")
+}
+
+type filterOr []Filter
+
+func (f filterOr) Match(block Block) bool {
+	for _, filter := range f {
+		if filter.Match(block) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f filterOr) String() string {
+	return joinFilters(f, " || ")
+}
+
+func joinFilters(filters []Filter, sep string) string {
+	parts := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		parts = append(parts, filter.String())
+	}
+
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+func quoteFilterWord(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n()!&|=\"") || isFilterKeyword(s) {
+		return fmt.Sprintf("%q", s)
+	}
+
+	return s
+}
+
+func isFilterKeyword(s string) bool {
+	return s == "and" || s == "or" || s == "not"
+}
+
+// TagsFilter returns a filter matching blocks that have all of tags
+// and none of excludeTags.
+func TagsFilter(tags, excludeTags []string) Filter {
+	filter := filterAnd{}
+
+	for _, tag := range tags {
+		filter = append(filter, filterTag{tag: tag})
+	}
+
+	if len(excludeTags) > 0 {
+		exclude := filterOr{}
+		for _, tag := range excludeTags {
+			exclude = append(exclude, filterTag{tag: tag})
+		}
+
+		filter = append(filter, filterNot{filter: exclude})
+	}
+
+	return filter
+}
+
+// ParseFilter parses a filter expression. An empty expression
+// matches every block.
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return filterAll{}, nil
+	}
+
+	p := &filterParser{expr: expr, tokens: tokens}
+
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek().value)
+	}
+
+	return filter, nil
+}
+
+type filterTokenKind int
+
+const (
+	tokenWord filterTokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenEqual
+	tokenNotEqual
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	value string
+	pos   int
+}
+
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+func lexFilter(expr string) ([]filterToken, error) { //nolint:cyclop
+	tokens := []filterToken{}
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
+			tokens = append(tokens, filterToken{kind: tokenAnd, value: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, filterToken{kind: tokenOr, value: "||", pos: i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "!="):
+			tokens = append(tokens, filterToken{kind: tokenNotEqual, value: "!=", pos: i})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, filterToken{kind: tokenNot, value: "!", pos: i})
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: tokenClose, value: ")", pos: i})
+			i++
+		case c == '=':
+			tokens = append(tokens, filterToken{kind: tokenEqual, value: "=", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("filter %q: position %d: %w", expr, i, errUnterminatedQuote)
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenWord, value: expr[i+1 : i+1+end], pos: i})
+			i += end + 2 //nolint:mnd
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n()!&|=\"'", rune(expr[i])) {
+				i++
+			}
+
+			if start == i {
+				return nil, fmt.Errorf("filter %q: position %d: unexpected %q", expr, i, expr[i])
+			}
+
+			tokens = append(tokens, keywordToken(filterToken{kind: tokenWord, value: expr[start:i], pos: start}))
+		}
+	}
+
+	return tokens, nil
+}
+
+func keywordToken(token filterToken) filterToken {
+	switch token.value {
+	case "and":
+		token.kind = tokenAnd
+	case "or":
+		token.kind = tokenOr
+	case "not":
+		token.kind = tokenNot
+	}
+
+	return token
+}
+
+type filterParser struct {
+	expr   string
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) accept(kind filterTokenKind) bool {
+	if p.done() || p.peek().kind != kind {
+		return false
+	}
+
+	p.pos++
+
+	return true
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	pos := len(p.expr)
+	if !p.done() {
+		pos = p.peek().pos
+	}
+
+	return fmt.Errorf("filter %q: position %d: %s", p.expr, pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	filter, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := filterOr{filter}
+
+	for p.accept(tokenOr) {
+		filter, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, filter)
+	}
+
+	if len(ret) == 1 {
+		return ret[0], nil
+	}
+
+	return ret, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	filter, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := filterAnd{filter}
+
+	for p.accept(tokenAnd) {
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, filter)
+	}
+
+	if len(ret) == 1 {
+		return ret[0], nil
+	}
+
+	return ret, nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	if p.done() {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	switch {
+	case p.accept(tokenNot):
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return filterNot{filter: filter}, nil
+	case p.accept(tokenOpen):
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.accept(tokenClose) {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+
+		return filter, nil
+	case p.peek().kind == tokenWord:
+		return p.parseTerm()
+	}
+
+	return nil, p.errorf("unexpected %q", p.peek().value)
+}
+
+func (p *filterParser) parseTerm() (Filter, error) {
+	word := p.peek().value
+	p.pos++
+
+	negate := false
+
+	switch {
+	case p.accept(tokenEqual):
+	case p.accept(tokenNotEqual):
+		negate = true
+	default:
+		return filterTag{tag: word}, nil
+	}
+
+	if p.done() || p.peek().kind != tokenWord {
+		return nil, p.errorf("missing value for %q", word)
+	}
+
+	var filter Filter = filterAttr{key: word, pattern: p.peek().value}
+
+	if word == "section" {
+		section, err := ParseSection(p.peek().value)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		filter = section
+	}
+
+	p.pos++
+
+	if negate {
+		filter = filterNot{filter: filter}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,120 @@
+package mdextract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expr     string
+		expected string
+	}{
+		"empty":       {"", ""},
+		"tag":         {"go", "go"},
+		"and":         {"go && ci", "(go && ci)"},
+		"or":          {"go || golang", "(go || golang)"},
+		"not":         {"!slow", "!slow"},
+		"keywords":    {"go and not slow or bash", "((go && !slow) || bash)"},
+		"precedence":  {"a || b && c", "(a || (b && c))"},
+		"parentheses": {"bash && (ci || smoke) && !slow", "(bash && (ci || smoke) && !slow)"},
+		"attribute":   {"file=*.go", "file=*.go"},
+		"not equal":   {"file!=main.go", "!file=main.go"},
+		"quoted":      {`title="my file.go"`, `title="my file.go"`},
+		"quoted tag":  {`"and"`, `"and"`},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := ParseFilter(cas.expr)
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, filter.String())
+		})
+	}
+}
+
+func TestParseFilter_Error(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"missing operand":     "go &&",
+		"missing parenthesis": "(go || bash",
+		"extra parenthesis":   "go)",
+		"missing value":       "file=",
+		"unterminated quote":  `"go`,
+		"double operator":     "go && || bash",
+		"only not":            "!",
+		"single ampersand":    "go & bash",
+	}
+
+	for title, expr := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseFilter(expr)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		expr     string
+		info     string
+		expected bool
+	}{
+		"empty":                {"", "go", true},
+		"tag":                  {"go", "go ci", true},
+		"tag no match":         {"go", "golang ci", false},
+		"any of":               {"go || golang", "golang", true},
+		"complex match":        {"bash && (ci || smoke) && !slow", "bash smoke", true},
+		"complex excluded":     {"bash && (ci || smoke) && !slow", "bash ci slow", false},
+		"complex no match":     {"bash && (ci || smoke) && !slow", "bash", false},
+		"attribute glob":       {"file=*.go", "go file=main.go", true},
+		"attribute no match":   {"file=*.go", "sh file=run.sh", false},
+		"attribute missing":    {"file=*", "go", false},
+		"attribute not equal":  {"file!=main.go", "go file=other.go", true},
+		"attribute with path":  {"file=pkg/*.go", "go file=pkg/main.go", true},
+		"attribute and tag":    {"go && file=*.go", "go file=main.go", true},
+		"not attribute absent": {"!file=*", "go", true},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := ParseFilter(cas.expr)
+			require.NoError(t, err)
+
+			blocks, err := Blocks([]byte("```" + cas.info + "\ncode\n```\n"))
+			require.NoError(t, err)
+			require.Len(t, blocks, 1)
+
+			assert.Equal(t, cas.expected, filter.Match(blocks[0]))
+		})
+	}
+}
+
+func TestSingle_Extract_Filter(t *testing.T) {
+	t.Parallel()
+
+	filter, err := ParseFilter("go && (ci || noci) && !file=*")
+	require.NoError(t, err)
+
+	single := Single{
+		ExcludeTags: []string{"noci"},
+		Filter:      filter,
+	}
+
+	result, err := single.ExtractFromFile("single.md")
+	require.NoError(t, err)
+	assert.Equal(t, "code block with go with tag ci\ncode block inside comment with tag ci\n", result)
+}
@@ -0,0 +1,200 @@
+package mdextract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Format is the output format of extracted code blocks.
+type Format string
+
+const (
+	// FormatText concatenates the contents of the code blocks.
+	FormatText Format = "text"
+	// FormatJSON writes a single JSON document with all code blocks and
+	// a summary.
+	FormatJSON Format = "json"
+	// FormatNDJSON writes one JSON record per line for each code block,
+	// followed by a summary record.
+	FormatNDJSON Format = "ndjson"
+)
+
+// ErrUnknownFormat is returned for unknown output formats.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat parses the name of an output format. An empty string is
+// FormatText.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSON, FormatNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownFormat, s)
+	}
+}
+
+// Record is the machine-readable representation of an extracted code
+// block.
+type Record struct {
+	// Type is always "block", to tell records and the summary apart in
+	// NDJSON.
+	Type       string            `json:"type"`
+	Content    string            `json:"content"`
+	Language   string            `json:"language,omitempty"`
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+	ID         string            `json:"id,omitempty"`
+	Source     string            `json:"source"`
+	StartLine  int               `json:"start_line"`
+	EndLine    int               `json:"end_line"`
+	InComment  bool              `json:"in_comment"`
+	Visibility Visibility        `json:"visibility"`
+	Headings   []string          `json:"headings,omitempty"`
+	// File is the target file in multi mode.
+	File string `json:"file,omitempty"`
+}
+
+// NewRecord returns the record of block. file is the target file in
+// multi mode and empty otherwise.
+func NewRecord(block Block, file string) Record {
+	tags := block.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	attrs := block.Attributes
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+
+	return Record{
+		Type:       "block",
+		Content:    block.Content,
+		Language:   block.Language(),
+		Tags:       tags,
+		Attributes: attrs,
+		ID:         block.ID,
+		Source:     block.Source,
+		StartLine:  block.StartLine,
+		EndLine:    block.EndLine,
+		InComment:  block.Visibility == VisibilityComment,
+		Visibility: block.Visibility,
+		Headings:   block.Headings,
+		File:       file,
+	}
+}
+
+// Records returns the records of the code blocks matching the criteria
+// and the blocks they require, see Resolve.
+func (single Single) Records(blocks []Block) ([]Record, error) {
+	if err := single.validate(blocks); err != nil {
+		return nil, err
+	}
+
+	resolved, err := single.Resolve(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Record{}
+	for _, block := range resolved {
+		ret = append(ret, NewRecord(block, ""))
+	}
+
+	return ret, nil
+}
+
+// Records returns the records of the code blocks of each file, see
+// Files.
+func (multi *Multi) Records(blocks []Block) ([]Record, error) {
+	files, err := multi.Files(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := multi.validate(blocks, files); err != nil {
+		return nil, err
+	}
+
+	ret := []Record{}
+
+	for _, file := range files {
+		for _, block := range file.Blocks {
+			ret = append(ret, NewRecord(block, file.Name))
+		}
+	}
+
+	return ret, nil
+}
+
+// Summary summarizes the records written by WriteRecords.
+type Summary struct {
+	// Type is always "summary".
+	Type string `json:"type"`
+	// Blocks is the number of code blocks.
+	Blocks int `json:"blocks"`
+	// Sources are the markdown files the code blocks were found in.
+	Sources []string `json:"sources"`
+	// Files are the target files in multi mode.
+	Files []string `json:"files,omitempty"`
+}
+
+// NewSummary returns the summary of the records.
+func NewSummary(records []Record) Summary {
+	summary := Summary{
+		Type:    "summary",
+		Blocks:  len(records),
+		Sources: []string{},
+	}
+
+	for _, record := range records {
+		if !slices.Contains(summary.Sources, record.Source) {
+			summary.Sources = append(summary.Sources, record.Source)
+		}
+
+		if record.File != "" && !slices.Contains(summary.Files, record.File) {
+			summary.Files = append(summary.Files, record.File)
+		}
+	}
+
+	return summary
+}
+
+// WriteRecords writes the records and their summary to w in the given
+// format. FormatJSON writes an object with the keys "blocks" and
+// "summary", FormatNDJSON writes one line per record followed by the
+// summary.
+func WriteRecords(w io.Writer, format Format, records []Record) error {
+	summary := NewSummary(records)
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(struct {
+			Blocks  []Record `json:"blocks"`
+			Summary Summary  `json:"summary"`
+		}{
+			Blocks:  records,
+			Summary: summary,
+		})
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+
+		return encoder.Encode(summary)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
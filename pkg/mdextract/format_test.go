@@ -0,0 +1,102 @@
+package mdextract
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formatInput = "# Setup\n\n```go file=main.go\npackage main\n```\n\n<!--\n```sh ci\necho hidden\n```\n-->\n"
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]Format{
+		"":       FormatText,
+		"text":   FormatText,
+		"json":   FormatJSON,
+		"ndjson": FormatNDJSON,
+	} {
+		format, err := ParseFormat(input)
+		require.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+
+	_, err := ParseFormat("yaml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestWriteRecords_JSON(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(formatInput))
+	require.NoError(t, err)
+
+	records, err := Single{}.Records(blocks)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteRecords(buf, FormatJSON, records))
+	assert.JSONEq(t, `{
+		"blocks": [
+			{
+				"type": "block",
+				"content": "package main\n",
+				"language": "go",
+				"tags": ["go"],
+				"attributes": {"file": "main.go"},
+				"source": "doc.md",
+				"start_line": 3,
+				"end_line": 5,
+				"in_comment": false,
+				"visibility": "visible",
+				"headings": ["Setup"]
+			},
+			{
+				"type": "block",
+				"content": "echo hidden\n",
+				"language": "sh",
+				"tags": ["sh", "ci"],
+				"attributes": {},
+				"source": "doc.md",
+				"start_line": 8,
+				"end_line": 10,
+				"in_comment": true,
+				"visibility": "comment",
+				"headings": ["Setup"]
+			}
+		],
+		"summary": {"type": "summary", "blocks": 2, "sources": ["doc.md"]}
+	}`, buf.String())
+}
+
+func TestWriteRecords_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(formatInput))
+	require.NoError(t, err)
+
+	records, err := (&Multi{}).Records(blocks)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteRecords(buf, FormatNDJSON, records))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"file":"main.go"`)
+	assert.JSONEq(t, `{"type": "summary", "blocks": 1, "sources": ["doc.md"], "files": ["main.go"]}`, lines[1])
+}
+
+func TestWriteRecords_Empty(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteRecords(buf, FormatJSON, []Record{}))
+	assert.JSONEq(t, `{"blocks": [], "summary": {"type": "summary", "blocks": 0, "sources": []}}`, buf.String())
+
+	require.ErrorIs(t, WriteRecords(buf, FormatText, nil), ErrUnknownFormat)
+}
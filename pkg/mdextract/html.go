@@ -0,0 +1,141 @@
+package mdextract
+
+import (
+	"bytes"
+	"regexp"
+)
+
+var (
+	commentOpen  = []byte("<!--")
+	commentClose = []byte("-->")
+
+	detailsOpen  = regexp.MustCompile(`(?i)<details(?:\s[^>]*)?>`)
+	detailsClose = regexp.MustCompile(`(?i)</details\s*>`)
+)
+
+// htmlElement is an HTML comment or <details> element whose content
+// may contain code blocks.
+type htmlElement struct {
+	// offset is the position of content in the HTML block.
+	offset  int
+	content []byte
+	details bool
+}
+
+// htmlElements returns the top-level comments and <details> elements
+// in an HTML block. Unterminated elements extend to the end of the
+// block.
+func htmlElements(literal []byte) []htmlElement {
+	ret := []htmlElement{}
+
+	for pos := 0; pos < len(literal); {
+		rest := literal[pos:]
+		comment := bytes.Index(rest, commentOpen)
+		details := detailsOpen.FindIndex(rest)
+
+		switch {
+		case comment >= 0 && (details == nil || comment < details[0]):
+			start := pos + comment + len(commentOpen)
+
+			end := bytes.Index(literal[start:], commentClose)
+			if end < 0 {
+				return append(ret, htmlElement{offset: start, content: literal[start:]})
+			}
+
+			ret = append(ret, htmlElement{offset: start, content: literal[start : start+end]})
+			pos = start + end + len(commentClose)
+		case details != nil:
+			start := pos + details[1]
+			end, next := closingDetails(literal, start)
+			ret = append(ret, htmlElement{offset: start, content: literal[start:end], details: true})
+			pos = next
+		default:
+			return ret
+		}
+	}
+
+	return ret
+}
+
+// closingDetails returns the offset of the </details> tag closing the
+// element whose content starts at start and the offset after the tag.
+func closingDetails(literal []byte, start int) (int, int) {
+	depth := 1
+
+	for pos := start; ; {
+		closing := detailsClose.FindIndex(literal[pos:])
+		if closing == nil {
+			return len(literal), len(literal)
+		}
+
+		if opening := detailsOpen.FindIndex(literal[pos:]); opening != nil && opening[0] < closing[0] {
+			depth++
+			pos += opening[1]
+
+			continue
+		}
+
+		depth--
+		if depth == 0 {
+			return pos + closing[0], pos + closing[1]
+		}
+
+		pos += closing[1]
+	}
+}
+
+// commentRanges returns the offsets of HTML comments in data that start
+// a line after up to three spaces or directly follow another comment,
+// skipping fenced code blocks. The markdown parser only recognizes
+// comments as HTML blocks if they start without indentation and
+// nothing follows the closing "-->", others end up as paragraphs
+// around the code blocks inside.
+func commentRanges(data []byte) [][2]int {
+	ret := [][2]int{}
+	fence := ""
+
+	for off := 0; off < len(data); {
+		line, next := nextLine(data, off)
+
+		if fence != "" {
+			if string(bytes.TrimSpace(stripPrefix(line))) == fence {
+				fence = ""
+			}
+
+			off = next
+
+			continue
+		}
+
+		// four spaces of indentation are an indented code block
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 { //nolint:mnd
+			off = next
+			continue
+		}
+
+		if marker, _, ok := parseFenceLine(line); ok {
+			fence = marker
+			off = next
+
+			continue
+		}
+
+		if !bytes.HasPrefix(trimmed, commentOpen) {
+			off = next
+			continue
+		}
+
+		start := off + len(line) - len(trimmed)
+
+		end := bytes.Index(data[start+len(commentOpen):], commentClose)
+		if end < 0 {
+			return append(ret, [2]int{start, len(data)})
+		}
+
+		off = start + len(commentOpen) + end + len(commentClose)
+		ret = append(ret, [2]int{start, off})
+	}
+
+	return ret
+}
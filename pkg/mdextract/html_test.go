@@ -0,0 +1,170 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLElements(t *testing.T) {
+	t.Parallel()
+
+	type element struct {
+		content string
+		details bool
+	}
+
+	cases := map[string][]element{
+		"<!-- a -->":                      {{" a ", false}},
+		"<!-- a --><!--b-->":              {{" a ", false}, {"b", false}},
+		"<!-- unterminated":               {{" unterminated", false}},
+		"<div>\n<!-- a -->\n</div>":       {{" a ", false}},
+		"<div>\n```sh\necho\n```\n</div>": {},
+		"<DETAILS open>\n<summary>x</summary>\nbody\n</Details>": {
+			{"\n<summary>x</summary>\nbody\n", true},
+		},
+		"<details>\n<details>inner</details>\n</details><!--c-->": {
+			{"\n<details>inner</details>\n", true},
+			{"c", false},
+		},
+	}
+
+	for literal, expected := range cases {
+		t.Run(literal, func(t *testing.T) {
+			t.Parallel()
+
+			actual := []element{}
+			for _, e := range htmlElements([]byte(literal)) {
+				assert.Equal(t, string(e.content), literal[e.offset:e.offset+len(e.content)])
+				actual = append(actual, element{string(e.content), e.details})
+			}
+
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestCommentRanges(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"text\n<!-- a -->\n":                  {"<!-- a -->"},
+		"  <!--\n```sh\necho\n```\n--> after": {"<!--\n```sh\necho\n```\n-->"},
+		"<!-- a --> <!-- b\n-->":              {"<!-- a -->", "<!-- b\n-->"},
+		"```\n<!--\n```\n":                    {},
+		"    <!--\n    code\n":                {},
+		"<!-- unterminated\n":                 {"<!-- unterminated\n"},
+		"text <!-- inline -->\n":              {},
+	}
+
+	for data, expected := range cases {
+		t.Run(data, func(t *testing.T) {
+			t.Parallel()
+
+			actual := []string{}
+			for _, r := range commentRanges([]byte(data)) {
+				actual = append(actual, data[r[0]:r[1]])
+			}
+
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestBlocks_Visibility(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```sh",
+		"echo visible",
+		"```",
+		"",
+		"  <!--",
+		"```sh",
+		"echo indented comment",
+		"```",
+		"-->",
+		"",
+		"<!-- first --><!--",
+		"```sh",
+		"echo second comment",
+		"```",
+		"-->",
+		"",
+		"<div>",
+		"```sh",
+		"echo div",
+		"```",
+		"</div>",
+		"",
+		"<details>",
+		"<summary>Full example</summary>",
+		"",
+		"```sh",
+		"echo details",
+		"```",
+		"",
+		"<!--",
+		"```sh",
+		"echo details comment",
+		"```",
+		"-->",
+		"",
+		"</details>",
+		"",
+	}, "\n")
+
+	blocks, err := parseBlocks("doc.md", []byte(input))
+	require.NoError(t, err)
+
+	type summary struct {
+		content    string
+		visibility Visibility
+		line       int
+	}
+
+	actual := []summary{}
+	for _, block := range blocks {
+		actual = append(actual, summary{block.Content, block.Visibility, block.StartLine})
+	}
+
+	assert.Equal(t, []summary{
+		{"echo visible\n", VisibilityVisible, 1},
+		{"echo indented comment\n", VisibilityComment, 6},
+		{"echo second comment\n", VisibilityComment, 12},
+		{"echo details\n", VisibilityDetails, 26},
+		{"echo details comment\n", VisibilityComment, 31},
+	}, actual)
+
+	for visibility, expected := range map[string]string{
+		"":                "echo visible\necho indented comment\necho second comment\necho details comment\n",
+		"visible":         "echo visible\n",
+		"visible,details": "echo visible\necho details\n",
+		"all":             "echo visible\necho indented comment\necho second comment\necho details\necho details comment\n",
+	} {
+		single := Single{}
+		if visibility != "" {
+			single.Visibility, err = ParseVisibility(visibility)
+			require.NoError(t, err)
+		}
+
+		extracted, err := single.ExtractBlocks(blocks)
+		require.NoError(t, err)
+		assert.Equal(t, expected, extracted, visibility)
+	}
+}
+
+func TestBlocks_CommentAtEnd(t *testing.T) {
+	t.Parallel()
+
+	// without a trailing newline the comment is parsed as part of
+	// a paragraph
+	blocks, err := Blocks([]byte("text\n\n<!--\n```sh\necho hidden\n```\n-->"))
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "echo hidden\n", blocks[0].Content)
+	assert.Equal(t, VisibilityComment, blocks[0].Visibility)
+	assert.Equal(t, 4, blocks[0].StartLine)
+}
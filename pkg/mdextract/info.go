@@ -0,0 +1,236 @@
+package mdextract
+
+import (
+	"strings"
+)
+
+// splitInfo splits an info string on spaces. Spaces inside single or
+// double quotes do not split, e.g. `title="my file.go"` is a single
+// word.
+func splitInfo(s string) []string {
+	return splitQuoted(s, ' ')
+}
+
+// splitQuoted splits s on sep, ignoring separators inside single or
+// double quotes. Empty words are dropped.
+func splitQuoted(s string, sep rune) []string {
+	ret := []string{}
+
+	var (
+		word  strings.Builder
+		quote rune
+	)
+
+	flush := func() {
+		if w := strings.TrimSpace(word.String()); w != "" {
+			ret = append(ret, w)
+		}
+
+		word.Reset()
+	}
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == sep:
+			flush()
+			continue
+		}
+
+		word.WriteRune(r)
+	}
+
+	flush()
+
+	return ret
+}
+
+// unquote removes matching single or double quotes around s.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// infoString is a parsed info string of a fenced code block.
+type infoString struct {
+	language string
+	id       string
+	tags     []string
+	attrs    map[string]string
+	// braced is true if the info string used the curly brace
+	// attribute syntax of Pandoc, Quarto or R Markdown.
+	braced bool
+}
+
+// parseInfo parses an info string into tags and key=value attributes.
+// Values may be quoted to contain spaces. If an attribute is given
+// multiple times the last value wins.
+//
+// Besides the plain syntax `go file=main.go ci` the curly brace syntax
+// of Pandoc (`{.python #setup file=run.py}`) and R Markdown or Quarto
+// (`{r setup, eval=FALSE}`) is recognized.
+func parseInfo(b []byte) infoString {
+	info := infoString{attrs: map[string]string{}}
+	s := strings.TrimSpace(string(b))
+
+	prefix, inner, ok := cutBraces(s)
+	if ok {
+		s = prefix
+		info.braced = true
+	}
+
+	words := splitInfo(s)
+	for _, word := range words {
+		info.addWord(word)
+	}
+
+	if len(words) > 0 && len(info.tags) > 0 && info.tags[0] == unquote(words[0]) {
+		info.language = info.tags[0]
+	}
+
+	if !ok {
+		return info
+	}
+
+	first := firstWord(inner)
+	if strings.HasPrefix(first, ".") || strings.HasPrefix(first, "#") || strings.Contains(first, "=") {
+		info.parsePandoc(inner)
+	} else {
+		info.parseChunk(inner)
+	}
+
+	return info
+}
+
+// cutBraces splits `prefix {inner}` into prefix and inner.
+func cutBraces(s string) (string, string, bool) {
+	if !strings.HasSuffix(s, "}") {
+		return "", "", false
+	}
+
+	i := strings.IndexByte(s, '{')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(s[:i]), s[i+1 : len(s)-1], true
+}
+
+func firstWord(s string) string {
+	words := splitQuoted(strings.ReplaceAll(s, ",", " "), ' ')
+	if len(words) == 0 {
+		return ""
+	}
+
+	return words[0]
+}
+
+func (info *infoString) addWord(word string) {
+	key, value, ok := strings.Cut(word, "=")
+	if !ok || key == "" {
+		info.tags = append(info.tags, unquote(word))
+		return
+	}
+
+	info.attrs[key] = unquote(value)
+}
+
+// parsePandoc parses Pandoc attributes, e.g. `.python #setup k=v`.
+// Classes become tags and the first class is the language if the
+// info string has no language before the braces.
+func (info *infoString) parsePandoc(s string) {
+	for _, word := range splitInfo(s) {
+		switch {
+		case strings.HasPrefix(word, ".") && len(word) > 1:
+			info.tags = append(info.tags, word[1:])
+			if info.language == "" {
+				info.language = word[1:]
+			}
+		case strings.HasPrefix(word, "#") && len(word) > 1:
+			info.id = word[1:]
+		default:
+			info.addWord(word)
+		}
+	}
+}
+
+// parseChunk parses R Markdown and Quarto chunk headers, e.g.
+// `r setup, eval=FALSE`. The first word is the engine and used as the
+// language, the first word without a value is the chunk label.
+func (info *infoString) parseChunk(s string) {
+	engine, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	engine, options, _ := strings.Cut(engine, ",")
+	rest = options + "," + rest
+
+	if engine != "" {
+		info.tags = append(info.tags, engine)
+		if info.language == "" {
+			info.language = engine
+		}
+	}
+
+	for _, option := range splitQuoted(rest, ',') {
+		key, value, ok := strings.Cut(option, "=")
+		key = strings.TrimSpace(key)
+
+		switch {
+		case !ok && info.id == "":
+			info.id = unquote(key)
+		case !ok:
+			info.tags = append(info.tags, unquote(key))
+		case key == "label":
+			info.id = unquote(strings.TrimSpace(value))
+		default:
+			info.attrs[key] = unquote(strings.TrimSpace(value))
+		}
+	}
+}
+
+// chunkOptionPrefixes are the comment prefixes of Quarto cell options.
+var chunkOptionPrefixes = []string{"#|", "//|", "--|"}
+
+// parseCellOptions parses Quarto cell options at the beginning of the
+// content, e.g. `#| label: setup`.
+func (info *infoString) parseCellOptions(content string) {
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+
+		var (
+			option string
+			found  bool
+		)
+
+		for _, prefix := range chunkOptionPrefixes {
+			if option, found = strings.CutPrefix(line, prefix); found {
+				break
+			}
+		}
+
+		if !found {
+			return
+		}
+
+		key, value, ok := strings.Cut(option, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+
+		if key == "label" {
+			info.id = value
+			continue
+		}
+
+		info.attrs[key] = value
+	}
+}
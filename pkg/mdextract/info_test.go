@@ -0,0 +1,261 @@
+package mdextract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInfo(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input string
+		tags  []string
+		attrs map[string]string
+	}{
+		"empty": {
+			input: "",
+			attrs: map[string]string{},
+		},
+		"tags": {
+			input: "go ci",
+			tags:  []string{"go", "ci"},
+			attrs: map[string]string{},
+		},
+		"attributes": {
+			input: "sh file=install.sh mode=0755 name=setup cwd=examples ci",
+			tags:  []string{"sh", "ci"},
+			attrs: map[string]string{
+				"file": "install.sh",
+				"mode": "0755",
+				"name": "setup",
+				"cwd":  "examples",
+			},
+		},
+		"double quoted value": {
+			input: `go title="my file.go" ci`,
+			tags:  []string{"go", "ci"},
+			attrs: map[string]string{"title": "my file.go"},
+		},
+		"single quoted value": {
+			input: `go title='my "file".go'`,
+			tags:  []string{"go"},
+			attrs: map[string]string{"title": `my "file".go`},
+		},
+		"quoted tag": {
+			input: `go "two words"`,
+			tags:  []string{"go", "two words"},
+			attrs: map[string]string{},
+		},
+		"unterminated quote": {
+			input: `go title="my file.go`,
+			tags:  []string{"go"},
+			attrs: map[string]string{"title": `"my file.go`},
+		},
+		"empty value": {
+			input: "go file=",
+			tags:  []string{"go"},
+			attrs: map[string]string{"file": ""},
+		},
+		"empty key is a tag": {
+			input: "go =value",
+			tags:  []string{"go", "=value"},
+			attrs: map[string]string{},
+		},
+		"value with equals": {
+			input: "go env=A=B",
+			tags:  []string{"go"},
+			attrs: map[string]string{"env": "A=B"},
+		},
+		"last attribute wins": {
+			input: "name=a name=b",
+			attrs: map[string]string{"name": "b"},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			info := parseInfo([]byte(cas.input))
+			assert.Equal(t, cas.tags, info.tags)
+			assert.Equal(t, cas.attrs, info.attrs)
+			assert.False(t, info.braced)
+		})
+	}
+}
+
+func TestParseInfo_File(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input string
+		file  string
+		tags  []string
+	}{
+		"empty": {
+			input: "",
+		},
+		"only lang": {
+			input: "go",
+			tags:  []string{"go"},
+		},
+		"only file": {
+			input: " file=main.go",
+			file:  "main.go",
+		},
+		"tag and file": {
+			input: "python file=script.py",
+			file:  "script.py",
+			tags:  []string{"python"},
+		},
+		"tag, file and tags": {
+			input: "js file=app.js tag1 tag2",
+			file:  "app.js",
+			tags:  []string{"js", "tag1", "tag2"},
+		},
+		"file and tags": {
+			input: " file=config.yaml prod debug",
+			file:  "config.yaml",
+			tags:  []string{"prod", "debug"},
+		},
+		"tags": {
+			input: "ruby test unit",
+			tags:  []string{"ruby", "test", "unit"},
+		},
+		"multiple file tags": {
+			input: " file=first.txt file=second.txt tagA",
+			file:  "second.txt",
+			tags:  []string{"tagA"},
+		},
+		"file with path": {
+			input: "go file=pkg/main.go",
+			file:  "pkg/main.go",
+			tags:  []string{"go"},
+		},
+		"file with spaces in name": {
+			input: "file=my file.txt",
+			file:  "my",
+			tags:  []string{"file.txt"},
+		},
+		"file with quoted spaces in name": {
+			input: `file="my file.txt" txt`,
+			file:  "my file.txt",
+			tags:  []string{"txt"},
+		},
+		"attributes are not tags": {
+			input: "sh file=install.sh mode=0755",
+			file:  "install.sh",
+			tags:  []string{"sh"},
+		},
+		"empty file tag": {
+			input: "file=",
+			file:  "",
+		},
+		"only file prefix": {
+			input: "file",
+			tags:  []string{"file"},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			info := parseInfo([]byte(cas.input))
+			assert.Equal(t, cas.file, info.attrs["file"])
+			assert.Equal(t, cas.tags, info.tags)
+		})
+	}
+}
+
+func TestParseInfo_Braced(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input    string
+		language string
+		id       string
+		tags     []string
+		attrs    map[string]string
+	}{
+		"pandoc": {
+			input:    "{.python #setup file=run.py}",
+			language: "python",
+			id:       "setup",
+			tags:     []string{"python"},
+			attrs:    map[string]string{"file": "run.py"},
+		},
+		"pandoc classes": {
+			input:    "{.haskell .numberLines startFrom=100}",
+			language: "haskell",
+			tags:     []string{"haskell", "numberLines"},
+			attrs:    map[string]string{"startFrom": "100"},
+		},
+		"pandoc with language": {
+			input:    "go {.ci #main}",
+			language: "go",
+			id:       "main",
+			tags:     []string{"go", "ci"},
+			attrs:    map[string]string{},
+		},
+		"pandoc id first": {
+			input: `{#setup .sh title="set up"}`,
+			id:    "setup",
+			tags:  []string{"sh"},
+			attrs: map[string]string{"title": "set up"},
+			// the first class is the language
+			language: "sh",
+		},
+		"rmarkdown": {
+			input:    "{r label, eval=FALSE}",
+			language: "r",
+			id:       "label",
+			tags:     []string{"r"},
+			attrs:    map[string]string{"eval": "FALSE"},
+		},
+		"rmarkdown without label": {
+			input:    "{r, echo=FALSE, fig.cap='a, b'}",
+			language: "r",
+			tags:     []string{"r"},
+			attrs:    map[string]string{"echo": "FALSE", "fig.cap": "a, b"},
+		},
+		"rmarkdown label option": {
+			input:    `{r, label="setup"}`,
+			language: "r",
+			id:       "setup",
+			tags:     []string{"r"},
+			attrs:    map[string]string{},
+		},
+		"quarto": {
+			input:    "{python}",
+			language: "python",
+			tags:     []string{"python"},
+			attrs:    map[string]string{},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			info := parseInfo([]byte(cas.input))
+			assert.True(t, info.braced)
+			assert.Equal(t, cas.language, info.language)
+			assert.Equal(t, cas.id, info.id)
+			assert.Equal(t, cas.tags, info.tags)
+			assert.Equal(t, cas.attrs, info.attrs)
+		})
+	}
+}
+
+func TestParseInfo_CellOptions(t *testing.T) {
+	t.Parallel()
+
+	info := parseInfo([]byte("{python}"))
+	info.parseCellOptions("#| label: setup\n#| file: run.py\n#| echo: false\nprint('#| not an option')\n#| eval: false\n")
+
+	assert.Equal(t, "setup", info.id)
+	assert.Equal(t, map[string]string{"file": "run.py", "echo": "false"}, info.attrs)
+}
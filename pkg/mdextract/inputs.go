@@ -0,0 +1,379 @@
+package mdextract
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MarkdownExtensions are the file extensions of markdown files that are
+// discovered in directories.
+var MarkdownExtensions = []string{".md", ".markdown"}
+
+// FindInputs expands the given inputs into a sorted list of markdown
+// files.
+//
+// Inputs can be files, directories or glob patterns. Files and "-" for
+// stdin are used as is. Directories are searched recursively for files
+// with one of the MarkdownExtensions. Glob patterns support "**" to
+// match any number of directories, e.g. "docs/**/*.md".
+//
+// Discovered files are skipped if they are ignored by a .gitignore file
+// or match one of the exclude patterns. Exclude patterns without
+// a slash match the name of a file or directory at any depth, other
+// patterns match the whole path, e.g. "docs/drafts/**".
+//
+// Each input is expanded in sorted order and files are only returned
+// once, in the order they were first found.
+func FindInputs(inputs []string, exclude []string) ([]string, error) {
+	finder := &inputFinder{
+		exclude: exclude,
+		seen:    map[string]bool{},
+	}
+
+	for _, input := range inputs {
+		if err := finder.find(input); err != nil {
+			return nil, err
+		}
+	}
+
+	return finder.files, nil
+}
+
+type inputFinder struct {
+	exclude []string
+	seen    map[string]bool
+	files   []string
+}
+
+func (finder *inputFinder) add(p string) {
+	if finder.seen[p] {
+		return
+	}
+
+	finder.seen[p] = true
+	finder.files = append(finder.files, p)
+}
+
+func (finder *inputFinder) find(input string) error {
+	if input == "-" {
+		finder.add(input)
+		return nil
+	}
+
+	if hasGlobMeta(input) {
+		return finder.glob(input)
+	}
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		finder.add(input)
+		return nil
+	}
+
+	return finder.walk(input, isMarkdown)
+}
+
+func (finder *inputFinder) glob(pattern string) error {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return err
+	}
+
+	root := globRoot(pattern)
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return finder.walk(root, func(p string) bool {
+		return matchGlob(pattern, filepath.ToSlash(p))
+	})
+}
+
+// walk adds all files below root that are accepted by match and
+// neither ignored nor excluded.
+func (finder *inputFinder) walk(root string, match func(p string) bool) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	rules, err := ancestorIgnoreRules(abs)
+	if err != nil {
+		return err
+	}
+
+	return finder.walkDir(root, abs, rules, match)
+}
+
+func (finder *inputFinder) walkDir(dir, abs string, rules ignoreRules, match func(p string) bool) error {
+	dirRules, err := readIgnoreFile(abs)
+	if err != nil {
+		return err
+	}
+
+	rules = append(slices.Clip(rules), dirRules...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		entryAbs := filepath.Join(abs, entry.Name())
+		isDir := entry.IsDir()
+
+		if entry.Name() == ".git" || rules.ignored(entryAbs, isDir) || finder.excluded(p) {
+			continue
+		}
+
+		if isDir {
+			if err := finder.walkDir(p, entryAbs, rules, match); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if match(p) {
+			finder.add(p)
+		}
+	}
+
+	return nil
+}
+
+func (finder *inputFinder) excluded(p string) bool {
+	p = filepath.ToSlash(p)
+
+	for _, pattern := range finder.exclude {
+		pattern = filepath.ToSlash(filepath.Clean(pattern))
+		if !strings.Contains(pattern, "/") {
+			if matchGlob(pattern, path.Base(p)) {
+				return true
+			}
+
+			continue
+		}
+
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isMarkdown(p string) bool {
+	return slices.Contains(MarkdownExtensions, strings.ToLower(filepath.Ext(p)))
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// globRoot returns the leading directories of pattern without glob
+// meta characters.
+func globRoot(pattern string) string {
+	segments := strings.Split(pattern, "/")
+
+	i := 0
+	for i < len(segments)-1 && !hasGlobMeta(segments[i]) {
+		i++
+	}
+
+	if i == 0 {
+		if pattern != "" && pattern[0] == '/' {
+			return "/"
+		}
+
+		return "."
+	}
+
+	return strings.Join(segments[:i], "/")
+}
+
+// matchGlob reports whether the slash separated name matches pattern.
+// Besides the syntax of path.Match, a "**" segment matches any number
+// of segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// ignoreRule is a single pattern of a .gitignore file.
+type ignoreRule struct {
+	// base is the directory of the .gitignore file.
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func (rule ignoreRule) match(abs string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(rule.base, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	if !rule.anchored {
+		return matchGlob(rule.pattern, path.Base(rel))
+	}
+
+	return matchGlob(rule.pattern, rel)
+}
+
+type ignoreRules []ignoreRule
+
+// ignored reports whether the file or directory at the absolute path
+// is ignored. The last matching rule wins.
+func (rules ignoreRules) ignored(abs string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.match(abs, isDir) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// readIgnoreFile reads the .gitignore file in dir if it exists.
+func readIgnoreFile(dir string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore")) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	rules := ignoreRules{}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	line = strings.TrimPrefix(line, "\\")
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = line
+
+	return rule, true
+}
+
+// ancestorIgnoreRules returns the rules of .gitignore files in the
+// parent directories of dir up to the root of the git repository.
+// No rules are returned if dir is not inside a git repository.
+func ancestorIgnoreRules(dir string) (ignoreRules, error) {
+	dirs := []string{}
+
+	for current := dir; !isRepoRoot(current); {
+		parent := filepath.Dir(current)
+		if parent == current {
+			// not inside a git repository
+			return nil, nil
+		}
+
+		current = parent
+		dirs = append(dirs, current)
+	}
+
+	rules := ignoreRules{}
+
+	// apply the outermost rules first so deeper rules take precedence
+	for _, d := range slices.Backward(dirs) {
+		dirRules, err := readIgnoreFile(d)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, dirRules...)
+	}
+
+	return rules, nil
+}
+
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
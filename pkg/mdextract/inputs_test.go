@@ -0,0 +1,164 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o700))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+}
+
+func TestFindInputs(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":                "",
+		".gitignore":               "generated/\n*.tmp.md\n!keep.tmp.md\n",
+		"README.md":                "",
+		"notes.txt":                "",
+		"docs/b.md":                "",
+		"docs/a.markdown":          "",
+		"docs/image.png":           "",
+		"docs/sub/c.md":            "",
+		"docs/sub/.gitignore":      "/local.md\n",
+		"docs/sub/local.md":        "",
+		"docs/sub/deep/local.md":   "",
+		"docs/drafts/d.md":         "",
+		"docs/x.tmp.md":            "",
+		"docs/keep.tmp.md":         "",
+		"generated/e.md":           "",
+		"docs/generated/f.md":      "",
+		"docs/sub/UPPER.MD":        "",
+		"docs/sub/deep/nested.md":  "",
+		"other/g.md":               "",
+		"other/drafts/ignored.md":  "",
+		"other/drafts.md/file.txt": "",
+	})
+
+	j := func(p string) string {
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	cases := map[string]struct {
+		inputs   []string
+		exclude  []string
+		expected []string
+	}{
+		"file": {
+			inputs:   []string{j("notes.txt")},
+			expected: []string{j("notes.txt")},
+		},
+		"directory": {
+			inputs: []string{j("docs")},
+			expected: []string{
+				j("docs/a.markdown"),
+				j("docs/b.md"),
+				j("docs/drafts/d.md"),
+				j("docs/keep.tmp.md"),
+				j("docs/sub/UPPER.MD"),
+				j("docs/sub/c.md"),
+				j("docs/sub/deep/local.md"),
+				j("docs/sub/deep/nested.md"),
+			},
+		},
+		"exclude": {
+			inputs:  []string{j("docs")},
+			exclude: []string{"drafts", "**/deep/*.md", "*.MD"},
+			expected: []string{
+				j("docs/a.markdown"),
+				j("docs/b.md"),
+				j("docs/keep.tmp.md"),
+				j("docs/sub/c.md"),
+			},
+		},
+		"glob": {
+			inputs: []string{j("docs/**/c.md"), j("*.md")},
+			expected: []string{
+				j("docs/sub/c.md"),
+				j("README.md"),
+			},
+		},
+		"glob any extension": {
+			inputs: []string{j("docs/*")},
+			expected: []string{
+				j("docs/a.markdown"),
+				j("docs/b.md"),
+				j("docs/image.png"),
+				j("docs/keep.tmp.md"),
+			},
+		},
+		"glob without matches": {
+			inputs: []string{j("missing/**/*.md")},
+		},
+		"stdin": {
+			inputs:   []string{"-", j("README.md"), "-"},
+			expected: []string{"-", j("README.md")},
+		},
+		"duplicates": {
+			inputs: []string{j("other/g.md"), j("other")},
+			expected: []string{
+				j("other/g.md"),
+				j("other/drafts/ignored.md"),
+			},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := FindInputs(cas.inputs, cas.exclude)
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, result)
+		})
+	}
+}
+
+func TestFindInputs_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := FindInputs([]string{"/non/existent/file.md"}, nil)
+	require.Error(t, err)
+
+	_, err = FindInputs([]string{"[.md"}, nil)
+	require.Error(t, err)
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		"exact":              {"a/b.md", "a/b.md", true},
+		"star":               {"a/*.md", "a/b.md", true},
+		"star no slash":      {"a/*.md", "a/b/c.md", false},
+		"double star":        {"a/**/*.md", "a/b/c/d.md", true},
+		"double star zero":   {"a/**/*.md", "a/d.md", true},
+		"double star prefix": {"**/d.md", "a/b/d.md", true},
+		"double star suffix": {"a/**", "a/b/d.md", true},
+		"no match":           {"a/**/*.md", "b/d.md", false},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, cas.expected, matchGlob(cas.pattern, cas.name))
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package mdextract
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Lint rules, see Linter.
+const (
+	// RuleLanguage reports fenced code blocks without a language.
+	RuleLanguage = "language"
+	// RuleTag reports tags that are not allowed.
+	RuleTag = "tag"
+	// RuleTypo reports tags and languages that look like misspellings.
+	RuleTypo = "typo"
+	// RuleFile reports empty file attributes and files written by
+	// multiple code blocks that are not explicitly concatenated.
+	RuleFile = "file"
+	// RuleIndented reports indented code blocks.
+	RuleIndented = "indented"
+	// RuleHidden reports code blocks in HTML comments without
+	// a visible code block in the same section.
+	RuleHidden = "hidden"
+	// RuleAttribute reports unknown attributes.
+	RuleAttribute = "attribute"
+	// RuleFence reports unterminated code fences.
+	RuleFence = "fence"
+)
+
+// ConcatTag marks code blocks that are concatenated to a file another
+// code block writes, see RuleFile.
+const ConcatTag = "concat"
+
+// extraLanguages are languages without interpreter or comment syntax
+// that are known for typo suggestions.
+var extraLanguages = []string{
+	"console", "css", "csv", "diff", "graphql", "html", "ini", "json", "markdown", "md",
+	"output", "plaintext", "proto", "text", "txt", "xml",
+}
+
+// knownLanguages returns the languages mdextract knows of.
+func knownLanguages() []string {
+	ret := slices.Collect(maps.Keys(languageComments))
+	ret = slices.AppendSeq(ret, maps.Keys(DefaultInterpreters))
+	ret = append(ret, extraLanguages...)
+	slices.Sort(ret)
+
+	return slices.Compact(ret)
+}
+
+// Finding is a violation of a lint rule.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Source  string `json:"source"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.Source, f.Line, f.Message, f.Rule)
+}
+
+func newFinding(rule string, block Block, format string, args ...any) Finding {
+	return Finding{
+		Rule:    rule,
+		Source:  block.Source,
+		Line:    block.StartLine,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Linter checks code blocks against documentation conventions.
+type Linter struct {
+	// Single selects the code blocks to check.
+	Single
+
+	// AllowedTags are the tags code blocks may have besides their
+	// language and ConcatTag. If empty all tags are allowed.
+	AllowedTags []string
+	// Disable are the rules not to check.
+	Disable []string
+}
+
+// FlagSet returns the flag set for the Linter struct.
+func (linter *Linter) FlagSet() *flag.FlagSet {
+	fs := linter.SelectFlagSet()
+	fs.Init("lint", flag.ExitOnError)
+
+	// the flags replace the values set before parsing, e.g. from the
+	// configuration file, on their first occurrence
+	allowedTagsSet, disableSet := false, false
+
+	fs.Func("allowed-tags", "Tags code blocks may have besides their language, "+
+		"comma-separated (default all)", func(s string) error {
+		if !allowedTagsSet {
+			linter.AllowedTags, allowedTagsSet = nil, true
+		}
+
+		linter.AllowedTags = append(linter.AllowedTags, split(s)...)
+
+		return nil
+	})
+	fs.Func("disable", "Lint rules not to check, comma-separated", func(s string) error {
+		if !disableSet {
+			linter.Disable, disableSet = nil, true
+		}
+
+		linter.Disable = append(linter.Disable, split(s)...)
+
+		return nil
+	})
+
+	return fs
+}
+
+// Lint returns the findings for the code blocks matching the criteria,
+// ordered by source and line. Code blocks of all visibilities are
+// checked unless Visibility is set.
+//
+// Fenced code blocks must have a language and only tags in AllowedTags.
+// Tags and languages close to a more common tag or a known language are
+// reported as typos. Multiple code blocks writing the same file must
+// have the ConcatTag, except for the first. Indented code blocks are
+// reported, as are code blocks in HTML comments without a visible code
+// block in the same language under the same heading. Problems reported
+// in strict mode like unknown attributes are findings as well.
+func (linter Linter) Lint(blocks []Block) []Finding {
+	if linter.Visibility == nil {
+		linter.Visibility = []Visibility{VisibilityVisible, VisibilityComment, VisibilityDetails}
+	}
+
+	// unterminated blocks are linted although they are never
+	// extracted
+	filter := linter.filter()
+	selected := slices.DeleteFunc(slices.Clone(blocks), func(block Block) bool {
+		return !linter.matches(filter, block)
+	})
+
+	ret := []Finding{}
+
+	ret = append(ret, linter.lintProblems(selected)...)
+	ret = append(ret, linter.lintTags(selected)...)
+	ret = append(ret, lintFiles(selected)...)
+
+	for _, block := range selected {
+		ret = append(ret, lintBlock(block)...)
+
+		if block.Visibility == VisibilityComment && !hasCounterpart(blocks, block) {
+			ret = append(ret, newFinding(RuleHidden, block,
+				"hidden code block without a visible %s code block in the same section", block.Language()))
+		}
+	}
+
+	ret = slices.DeleteFunc(ret, func(f Finding) bool {
+		return slices.Contains(linter.Disable, f.Rule)
+	})
+
+	slices.SortStableFunc(ret, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Line, b.Line))
+	})
+
+	return ret
+}
+
+// lintProblems returns the problems of strict mode as findings.
+func (linter Linter) lintProblems(blocks []Block) []Finding {
+	ret := []Finding{}
+
+	for _, problem := range linter.checkBlocks(blocks) {
+		var posErr *Error
+		if !errors.As(problem, &posErr) {
+			continue
+		}
+
+		rule := RuleFence
+
+		switch {
+		case errors.Is(problem, ErrEmptyFile):
+			rule = RuleFile
+		case errors.Is(problem, ErrUnknownAttribute):
+			rule = RuleAttribute
+		}
+
+		ret = append(ret, Finding{Rule: rule, Source: posErr.Source, Line: posErr.Line, Message: posErr.Err.Error()})
+	}
+
+	return ret
+}
+
+// lintBlock returns the findings of a single code block.
+func lintBlock(block Block) []Finding {
+	if block.FenceChar == 0 {
+		return []Finding{newFinding(RuleIndented, block, "indented code block, use a fenced code block with a language")}
+	}
+
+	lang := block.Language()
+	if lang == "" {
+		return []Finding{newFinding(RuleLanguage, block, "fenced code block without language")}
+	}
+
+	languages := knownLanguages()
+	if slices.Contains(languages, lang) {
+		return nil
+	}
+
+	if suggestion := suggest(lang, languages); suggestion != "" {
+		return []Finding{newFinding(RuleTypo, block, "unknown language %q, did you mean %q?", lang, suggestion)}
+	}
+
+	return nil
+}
+
+// tags returns the tags of block without its language.
+func tags(block Block) []string {
+	if lang := block.Language(); lang != "" && len(block.Tags) > 0 && block.Tags[0] == lang {
+		return block.Tags[1:]
+	}
+
+	return block.Tags
+}
+
+// lintTags returns tags that are not allowed or look like misspellings
+// of more common tags.
+func (linter Linter) lintTags(blocks []Block) []Finding {
+	counts := map[string]int{}
+
+	for _, block := range blocks {
+		for _, tag := range tags(block) {
+			counts[tag]++
+		}
+	}
+
+	ret := []Finding{}
+
+	for _, block := range blocks {
+		for _, tag := range tags(block) {
+			if tag == ConcatTag {
+				continue
+			}
+
+			if len(linter.AllowedTags) > 0 {
+				if slices.Contains(linter.AllowedTags, tag) {
+					continue
+				}
+
+				if suggestion := suggest(tag, linter.AllowedTags); suggestion != "" {
+					ret = append(ret, newFinding(RuleTag, block, "tag %q is not allowed, did you mean %q?", tag, suggestion))
+				} else {
+					ret = append(ret, newFinding(RuleTag, block, "tag %q is not allowed", tag))
+				}
+
+				continue
+			}
+
+			common := []string{}
+
+			for other, count := range counts {
+				if count > counts[tag] {
+					common = append(common, other)
+				}
+			}
+
+			slices.Sort(common)
+
+			if suggestion := suggest(tag, common); suggestion != "" {
+				ret = append(ret, newFinding(RuleTypo, block, "tag %q, did you mean %q?", tag, suggestion))
+			}
+		}
+	}
+
+	return ret
+}
+
+// lintFiles returns code blocks writing a file written by an earlier
+// code block without the ConcatTag.
+func lintFiles(blocks []Block) []Finding {
+	ret := []Finding{}
+	first := map[string]Block{}
+
+	for _, block := range blocks {
+		name := block.Attributes["file"]
+		if name == "" {
+			continue
+		}
+
+		prev, ok := first[name]
+		if !ok {
+			first[name] = block
+			continue
+		}
+
+		if !slices.Contains(block.Tags, ConcatTag) {
+			ret = append(ret, newFinding(RuleFile, block,
+				"file %q is also written at %s, add the %q tag to concatenate", name, prev.location(), ConcatTag))
+		}
+	}
+
+	return ret
+}
+
+// hasCounterpart reports whether a visible code block in the same
+// language is under the same headings as block.
+func hasCounterpart(blocks []Block, block Block) bool {
+	return slices.ContainsFunc(blocks, func(other Block) bool {
+		return other.Visibility != VisibilityComment &&
+			other.Source == block.Source &&
+			other.Language() == block.Language() &&
+			slices.Equal(other.Headings, block.Headings)
+	})
+}
+
+// suggest returns the candidate closest to word if it is close enough
+// to be a misspelling, otherwise an empty string.
+func suggest(word string, candidates []string) string {
+	best, bestDistance := "", 0
+
+	for _, candidate := range candidates {
+		if candidate == word {
+			continue
+		}
+
+		d := distance(word, candidate)
+		if d > 2 || d*3 > len(word) { //nolint:mnd
+			continue
+		}
+
+		if best == "" || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best
+}
+
+// distance returns the edit distance of a and b, counting insertions,
+// deletions, substitutions and transpositions of adjacent characters.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// rows i-2, i-1 and i of the distance matrix
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+// WriteFindings writes the findings to w in the given format.
+// FormatText writes one line per finding, FormatJSON a JSON array and
+// FormatNDJSON one JSON object per line.
+func WriteFindings(w io.Writer, format Format, findings []Finding) error {
+	switch format {
+	case FormatText:
+		for _, finding := range findings {
+			if _, err := fmt.Fprintln(w, finding); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(findings)
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+
+		for _, finding := range findings {
+			if err := encoder.Encode(finding); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
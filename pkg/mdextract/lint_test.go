@@ -0,0 +1,209 @@
+package mdextract
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinter_Lint(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input    []string
+		linter   Linter
+		expected []Finding
+	}{
+		"valid": {
+			input: []string{"# Title", "", "```sh ci", "echo ok", "```", "", "<!--", "```sh ci", "echo hidden", "```", "-->"},
+		},
+		"language": {
+			input:    []string{"```", "echo ok", "```"},
+			expected: []Finding{{Rule: RuleLanguage, Source: "doc.md", Line: 1, Message: "fenced code block without language"}},
+		},
+		"language typo": {
+			input: []string{"```pyhton", "print(1)", "```"},
+			expected: []Finding{
+				{Rule: RuleTypo, Source: "doc.md", Line: 1, Message: `unknown language "pyhton", did you mean "python"?`},
+			},
+		},
+		"unknown language": {
+			input: []string{"```mermaid", "graph TD;", "```"},
+		},
+		"tag typo": {
+			input: []string{
+				"```sh setup", "echo 1", "```", "", "```sh setup", "echo 2", "```", "", "```sh setpu", "echo 3", "```",
+			},
+			expected: []Finding{
+				{Rule: RuleTypo, Source: "doc.md", Line: 9, Message: `tag "setpu", did you mean "setup"?`},
+			},
+		},
+		"allowed tags": {
+			input: []string{
+				"```sh ci", "echo 1", "```", "", "```sh instal", "echo 2", "```", "", "```sh other", "echo 3", "```",
+			},
+			linter: Linter{AllowedTags: []string{"ci", "install"}},
+			expected: []Finding{
+				{Rule: RuleTag, Source: "doc.md", Line: 5, Message: `tag "instal" is not allowed, did you mean "install"?`},
+				{Rule: RuleTag, Source: "doc.md", Line: 9, Message: `tag "other" is not allowed`},
+			},
+		},
+		"file": {
+			input: []string{
+				"```sh file=run.sh", "echo 1", "```", "",
+				"```sh file=run.sh", "echo 2", "```", "",
+				"```sh file=run.sh concat", "echo 3", "```",
+			},
+			expected: []Finding{
+				{
+					Rule: RuleFile, Source: "doc.md", Line: 5,
+					Message: `file "run.sh" is also written at doc.md:1, add the "concat" tag to concatenate`,
+				},
+			},
+		},
+		"empty file": {
+			input:    []string{"```sh file=", "echo ok", "```"},
+			expected: []Finding{{Rule: RuleFile, Source: "doc.md", Line: 1, Message: "empty file attribute"}},
+		},
+		"attribute": {
+			input: []string{"```sh fiel=run.sh", "echo ok", "```"},
+			expected: []Finding{
+				{Rule: RuleAttribute, Source: "doc.md", Line: 1, Message: `unknown attribute "fiel", did you mean "file"?`},
+			},
+		},
+		"indented": {
+			input: []string{"Text", "", "    echo ok"},
+			expected: []Finding{
+				{
+					Rule: RuleIndented, Source: "doc.md", Line: 3,
+					Message: "indented code block, use a fenced code block with a language",
+				},
+			},
+		},
+		"hidden": {
+			input: []string{"# Title", "", "```go", "package main", "```", "", "<!--", "```sh", "echo hidden", "```", "-->"},
+			expected: []Finding{
+				{
+					Rule: RuleHidden, Source: "doc.md", Line: 8,
+					Message: "hidden code block without a visible sh code block in the same section",
+				},
+			},
+		},
+		"fence": {
+			input:    []string{"```sh", "echo ok"},
+			expected: []Finding{{Rule: RuleFence, Source: "doc.md", Line: 1, Message: "unterminated code fence"}},
+		},
+		"disable": {
+			input:  []string{"```", "echo ok", "```", "", "    echo indented"},
+			linter: Linter{Disable: []string{RuleLanguage, RuleIndented}},
+		},
+		"filtered": {
+			input:    []string{"```sh ci", "echo ok", "```", "", "```", "echo ok", "```"},
+			linter:   Linter{Single: Single{Tags: []string{"ci"}}},
+			expected: []Finding{},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := parseBlocks("doc.md", []byte(strings.Join(cas.input, "\n")))
+			require.NoError(t, err)
+
+			expected := cas.expected
+			if expected == nil {
+				expected = []Finding{}
+			}
+
+			assert.Equal(t, expected, cas.linter.Lint(blocks))
+		})
+	}
+}
+
+func TestLinter_FlagSet(t *testing.T) {
+	t.Parallel()
+
+	linter := &Linter{
+		Single:      Single{KnownAttributes: []string{"os"}},
+		AllowedTags: []string{"ci"},
+		Disable:     []string{RuleHidden},
+	}
+	require.NoError(t, linter.FlagSet().Parse([]string{
+		"-allowed-tags", "a", "-allowed-tags", "b,c", "-known-attributes", "arch",
+	}))
+
+	assert.Equal(t, []string{"a", "b", "c"}, linter.AllowedTags)
+	assert.Equal(t, []string{RuleHidden}, linter.Disable)
+	assert.Equal(t, []string{"arch"}, linter.KnownAttributes)
+}
+
+func TestSuggest(t *testing.T) {
+	t.Parallel()
+
+	candidates := []string{"bash", "python", "sh", "yaml"}
+
+	for word, expected := range map[string]string{
+		"pyhton": "python",
+		"pythn":  "python",
+		"yml":    "yaml",
+		"bsah":   "bash",
+		"sh":     "",
+		"ci":     "",
+		"ruby":   "",
+		"go":     "",
+		"":       "",
+	} {
+		assert.Equal(t, expected, suggest(word, candidates), word)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	for _, cas := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"python", "python", 0},
+		{"python", "pyhton", 1},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+	} {
+		assert.Equal(t, cas.expected, distance(cas.a, cas.b), cas.a+" "+cas.b)
+	}
+}
+
+func TestWriteFindings(t *testing.T) {
+	t.Parallel()
+
+	findings := []Finding{
+		{Rule: RuleLanguage, Source: "doc.md", Line: 1, Message: "fenced code block without language"},
+		{Rule: RuleIndented, Source: "doc.md", Line: 5, Message: "indented code block"},
+	}
+
+	cases := map[Format]string{
+		FormatText: "doc.md:1: fenced code block without language (language)\n" +
+			"doc.md:5: indented code block (indented)\n",
+		FormatNDJSON: `{"rule":"language","source":"doc.md","line":1,"message":"fenced code block without language"}` + "\n" +
+			`{"rule":"indented","source":"doc.md","line":5,"message":"indented code block"}` + "\n",
+	}
+
+	for format, expected := range cases {
+		buf := &bytes.Buffer{}
+		require.NoError(t, WriteFindings(buf, format, findings))
+		assert.Equal(t, expected, buf.String(), format)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteFindings(buf, FormatJSON, []Finding{}))
+	assert.Equal(t, "[]\n", buf.String())
+
+	require.ErrorIs(t, WriteFindings(buf, Format("xml"), findings), ErrUnknownFormat)
+}
@@ -0,0 +1,107 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidMode is returned for "mode" attributes that are not an
+	// octal file mode.
+	ErrInvalidMode = errors.New("invalid file mode")
+	// ErrModeConflict is returned if code blocks for the same file have
+	// different "mode" attributes.
+	ErrModeConflict = errors.New("conflicting file modes")
+)
+
+// parseMode parses an octal file mode like "0755" or "755".
+func parseMode(s string) (os.FileMode, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || v > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("%w %q", ErrInvalidMode, s)
+	}
+
+	return os.FileMode(v), nil
+}
+
+// executable adds the execute permission for everyone that may read.
+func executable(mode os.FileMode) os.FileMode {
+	return mode | (mode&0o444)>>2 //nolint:mnd
+}
+
+// mode returns the mode of file. A "mode" attribute on any of its
+// code blocks takes precedence over FileMode, code blocks disagreeing
+// on the mode are an error. If Executable is set files starting with
+// a shebang line are made executable. It returns 0 if the mode is not
+// set explicitly.
+func (multi *Multi) mode(file File) (os.FileMode, error) {
+	var (
+		mode  os.FileMode
+		first Block
+		set   bool
+	)
+
+	for _, block := range file.Blocks {
+		s, ok := block.Attributes["mode"]
+		if !ok {
+			continue
+		}
+
+		m, err := parseMode(s)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: file %q: %w", block.Source, block.StartLine, file.Name, err)
+		}
+
+		if set && m != mode {
+			return 0, fmt.Errorf("%s:%d: file %q: %w: %04o conflicts with %04o at %s:%d",
+				block.Source, block.StartLine, file.Name, ErrModeConflict, m, mode, first.Source, first.StartLine)
+		}
+
+		if !set {
+			mode, first, set = m, block, true
+		}
+	}
+
+	if set {
+		return mode, nil
+	}
+
+	if multi.Executable && len(file.Blocks) > 0 && strings.HasPrefix(file.Blocks[0].Content, "#!") {
+		return executable(multi.fileMode()), nil
+	}
+
+	return os.FileMode(multi.FileMode), nil
+}
+
+// Modes returns the file modes of the files assembled from the code
+// blocks matching the criteria, see Files.
+//
+// The mode of a file is set with a "mode" attribute in octal, e.g.
+// mode=0755, on any of its code blocks. Files without a "mode"
+// attribute use FileMode. If Executable is set, files whose first line
+// is a shebang are made executable. Files without an explicit mode are
+// left out, WriteModes keeps the mode of existing files for them.
+func (multi *Multi) Modes(blocks []Block) (map[string]os.FileMode, error) {
+	files, err := multi.Files(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]os.FileMode, len(files))
+
+	for _, file := range files {
+		mode, err := multi.mode(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if mode != 0 {
+			ret[file.Name] = mode
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,131 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]os.FileMode{
+		"0755":  0o755,
+		"755":   0o755,
+		"0o644": 0o644,
+		"0600":  0o600,
+	} {
+		mode, err := parseMode(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, mode, input)
+	}
+
+	for _, input := range []string{"", "rwx", "0999", "01777", "-1"} {
+		_, err := parseMode(input)
+		require.ErrorIs(t, err, ErrInvalidMode, input)
+	}
+}
+
+func TestMulti_Modes(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		multi    Multi
+		input    string
+		expected map[string]os.FileMode
+		err      error
+	}{
+		"default": {
+			input:    "```sh file=a.sh\n#!/bin/sh\n```\n",
+			expected: map[string]os.FileMode{},
+		},
+		"file mode": {
+			multi:    Multi{FileMode: 0o640},
+			input:    "```yaml file=a.yaml\na: b\n```\n",
+			expected: map[string]os.FileMode{"a.yaml": 0o640},
+		},
+		"mode attribute": {
+			input: "```sh file=install.sh mode=0755\necho\n```\n\n" +
+				"```yaml file=config.yaml\na: b\n```\n\n" +
+				"```sh file=install.sh\necho\n```\n",
+			expected: map[string]os.FileMode{"install.sh": 0o755},
+		},
+		"executable shebang": {
+			multi: Multi{Executable: true, FileMode: 0o644},
+			input: "```sh file=a.sh\n#!/bin/sh\n```\n\n" +
+				"```sh file=b.sh\necho\n```\n\n" +
+				"```sh file=c.sh mode=0600\n#!/bin/sh\n```\n",
+			expected: map[string]os.FileMode{"a.sh": 0o755, "b.sh": 0o644, "c.sh": 0o600},
+		},
+		"executable only readable": {
+			multi:    Multi{Executable: true},
+			input:    "```sh file=a.sh\n#!/bin/sh\n```\n",
+			expected: map[string]os.FileMode{"a.sh": 0o700},
+		},
+		"same mode": {
+			input:    "```sh file=a.sh mode=0755\n```\n\n```sh file=a.sh mode=755\n```\n",
+			expected: map[string]os.FileMode{"a.sh": 0o755},
+		},
+		"conflict": {
+			input: "```sh file=a.sh mode=0755\n```\n\n```sh file=a.sh mode=0644\n```\n",
+			err:   ErrModeConflict,
+		},
+		"invalid": {
+			input: "```sh file=a.sh mode=rwx\n```\n",
+			err:   ErrInvalidMode,
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := BlocksFromReader("doc.md", strings.NewReader(tc.input))
+			require.NoError(t, err)
+
+			modes, err := tc.multi.Modes(blocks)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, modes)
+		})
+	}
+}
+
+func TestMulti_Modes_ConflictPosition(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(
+		"```sh file=a.sh mode=0755\n```\n\n```sh file=a.sh mode=0644\n```\n"))
+	require.NoError(t, err)
+
+	_, err = (&Multi{}).Modes(blocks)
+	require.EqualError(t, err, `doc.md:4: file "a.sh": conflicting file modes: 0644 conflicts with 0755 at doc.md:1`)
+}
+
+func TestMulti_ExtractFromFileAndWrite_Modes(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "out")
+
+	mdFile := filepath.Join(tmpDir, "test.md")
+	markdown := "```sh file=install.sh mode=0755\n#!/bin/sh\n```\n\n```yaml file=config.yaml\na: b\n```\n"
+	require.NoError(t, os.WriteFile(mdFile, []byte(markdown), 0o600))
+
+	multi := &Multi{OutputDir: outputDir}
+	require.NoError(t, multi.ExtractFromFileAndWrite(mdFile))
+
+	for name, expected := range map[string]os.FileMode{"install.sh": 0o755, "config.yaml": 0o600} {
+		info, err := os.Stat(filepath.Join(outputDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, info.Mode().Perm(), name)
+	}
+}
@@ -1,14 +1,11 @@
 package mdextract
 
 import (
-	"bytes"
 	"flag"
+	"io"
+	"maps"
 	"os"
 	"strconv"
-	"strings"
-
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/ast"
 )
 
 // Multi goes through a markdown document and extracts code blocks
@@ -20,8 +17,20 @@ type Multi struct {
 	Single
 
 	// FileMode determines the mode when writing files.
-	// Default: 0600
+	// Default: the mode of an existing file, 0600 for new files
 	FileMode uint32
+	// OutputDir is the directory files are written to. The "file"
+	// attribute of code blocks is resolved relative to it and must
+	// not point outside of it.
+	// Default: current directory
+	OutputDir string
+	// Append appends to existing files instead of replacing them.
+	// Default: false
+	Append bool
+	// Executable makes files whose first line is a shebang executable
+	// unless a "mode" attribute is set.
+	// Default: false
+	Executable bool
 }
 
 const defaultFileMode = 0o600
@@ -53,8 +62,7 @@ func (u *uint32Value) Set(s string) error {
 		return err
 	}
 
-	uv := uint32(v)
-	u.value = &uv
+	*u.value = uint32(v)
 
 	return nil
 }
@@ -64,9 +72,14 @@ func (multi *Multi) FlagSet() *flag.FlagSet {
 	fs := multi.Single.FlagSet()
 	fs.Init("multi", flag.ExitOnError)
 
-	multi.FileMode = uint32(multi.fileMode())
 	flagValue := &uint32Value{value: &multi.FileMode}
-	fs.Var(flagValue, "file-mode", "File mode to use when writing files (in octal, e.g. 0600)")
+	fs.Var(flagValue, "file-mode",
+		"File mode to use when writing files in octal, e.g. 0600 (default: mode of an existing file or 0600)")
+	fs.BoolVar(&multi.Append, "append", false, "Append to output files instead of replacing them")
+	fs.BoolVar(&multi.Executable, "executable", false,
+		"Make files starting with a shebang line executable unless a mode attribute is set")
+	fs.StringVar(&multi.OutputDir, "output-dir", "",
+		"Directory to write files to in multi mode (default: current directory)")
 
 	return fs
 }
@@ -74,95 +87,159 @@ func (multi *Multi) FlagSet() *flag.FlagSet {
 // ExtractFromFile reads a markdown file from the given path and
 // extracts code blocks from it.
 func (multi *Multi) ExtractFromFile(path string) (map[string]string, error) {
-	data, err := os.ReadFile(path) //nolint:gosec
+	blocks, err := BlocksFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return multi.extract(blocks)
+}
+
+// ExtractFromReader reads markdown from r and extracts code blocks
+// from it.
+func (multi *Multi) ExtractFromReader(r io.Reader) (map[string]string, error) {
+	blocks, err := BlocksFromReader(multi.SourceName, r)
 	if err != nil {
 		return nil, err
 	}
 
-	return multi.Extract(data)
+	return multi.extract(blocks)
 }
 
 // ExtractFromFileAndWrite reads a markdown file from the given path,
 // extracts code blocks from it and writes the contents to files based
-// on the "file" tag.
+// on the "file" tag, see WriteModes.
 func (multi *Multi) ExtractFromFileAndWrite(path string) error {
-	contents, err := multi.ExtractFromFile(path)
+	blocks, err := BlocksFromFile(path)
 	if err != nil {
 		return err
 	}
 
-	for file, content := range contents {
-		if err := os.WriteFile(file, []byte(content), multi.fileMode()); err != nil {
-			return err
-		}
+	modes, err := multi.Modes(blocks)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	contents, err := multi.extract(blocks)
+	if err != nil {
+		return err
+	}
+
+	return multi.WriteModes(contents, modes)
+}
+
+// Extract extracts code blocks from the given markdown data and returns
+// a map of filenames to their corresponding code contents. The filename
+// is determined by the "file" tag in the code block's info string.
+func (multi *Multi) Extract(data []byte) (map[string]string, error) {
+	blocks, err := parseBlocks(multi.SourceName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return multi.extract(blocks)
+}
+
+// File is a file assembled from code blocks in multi mode.
+type File struct {
+	// Name is the value of the "file" attribute of the code blocks.
+	Name string
+	// Blocks are the code blocks making up the file in document order.
+	Blocks []Block
+}
+
+// Attributes returns the merged attributes of all code blocks of the
+// file. If code blocks disagree on an attribute the last one wins.
+func (file File) Attributes() map[string]string {
+	ret := map[string]string{}
+
+	for _, block := range file.Blocks {
+		maps.Copy(ret, block.Attributes)
+	}
+
+	return ret
 }
 
-func parseFileTag(b []byte) (string, []string) {
-	tags := parseTag(b)
+// Files returns the code blocks matching the criteria grouped by their
+// "file" attribute, in order of first appearance. Code blocks without
+// a "file" attribute are ignored.
+//
+// Code blocks required by the blocks of a file are added to the file
+// in dependency order, see Single.Resolve. Required code blocks with
+// a different "file" attribute are added to their own file instead.
+func (multi *Multi) Files(blocks []Block) ([]File, error) {
+	closure, err := resolve(blocks, multi.selected(blocks), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []File{}
+	seeds := [][]int{}
+	index := map[string]int{}
 
-	var (
-		file      string
-		otherTags []string
-	)
+	for _, i := range closure {
+		name := blocks[i].Attributes["file"]
+		if name == "" {
+			continue
+		}
 
-	for _, tag := range tags {
-		switch {
-		case strings.HasPrefix(tag, "file="):
-			file = strings.TrimPrefix(tag, "file=")
-		default:
-			otherTags = append(otherTags, tag)
+		j, ok := index[name]
+		if !ok {
+			j = len(ret)
+			index[name] = j
+			ret = append(ret, File{Name: name})
+			seeds = append(seeds, nil)
 		}
+
+		seeds[j] = append(seeds[j], i)
 	}
 
-	return file, otherTags
+	for j := range ret {
+		order, err := resolve(blocks, seeds[j], func(block Block) bool {
+			name := block.Attributes["file"]
+			return name == "" || name == ret[j].Name
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range order {
+			ret[j].Blocks = append(ret[j].Blocks, blocks[i])
+		}
+	}
+
+	return ret, nil
 }
 
-// Extract extracts code blocks from the given markdown data and returns
-// a map of filenames to their corresponding code contents. The filename
-// is determined by the "file" tag in the code block's info string.
-func (multi *Multi) Extract(data []byte) (map[string]string, error) {
-	ret := make(map[string]string)
-	node := markdown.Parse(data, nil)
-
-	ast.WalkFunc(node, ast.NodeVisitorFunc(func(node ast.Node, _ bool) ast.WalkStatus {
-		switch n := node.(type) {
-		case *ast.CodeBlock:
-			file, tags := parseFileTag(n.Info)
-			if file == "" {
-				return ast.GoToNext
-			}
-
-			if !multi.acceptBlock(tags) {
-				return ast.GoToNext
-			}
-
-			ret[file] += string(n.Literal)
-		case *ast.HTMLBlock:
-			// an HTML block might be a comment with a code block that
-			// should only be executed in e.g. CI
-			if multi.ExcludeComments {
-				return ast.GoToNext
-			}
-			// Strip the comments, parse as markdown and add the code
-			// blocks
-			comment := bytes.TrimPrefix(n.Literal, []byte("<!--"))
-			comment = bytes.TrimSuffix(comment, []byte("-->"))
-
-			contents, err := multi.Extract(comment)
-			if err != nil {
-				return ast.Terminate
-			}
-
-			for k, v := range contents {
-				ret[k] += v
-			}
+// ExtractBlocks returns a map of filenames to the contents of the code
+// blocks matching the criteria, see Files.
+func (multi *Multi) ExtractBlocks(blocks []Block) (map[string]string, error) {
+	return multi.extract(blocks)
+}
+
+func (multi *Multi) extract(blocks []Block) (map[string]string, error) {
+	files, err := multi.Files(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := multi.validate(blocks, files); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(files))
+
+	for _, file := range files {
+		fileBlocks, err := multi.process(blocks, file.Blocks)
+		if err != nil {
+			return nil, err
 		}
 
-		return ast.GoToNext
-	}))
+		ret[file.Name], err = multi.concat(fileBlocks)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return ret, nil
 }
@@ -10,80 +10,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestParseFileTag(t *testing.T) {
-	t.Parallel()
-
-	cases := map[string]struct {
-		input string
-		file  string
-		tags  []string
-	}{
-		"empty": {
-			input: "",
-		},
-		"only lang": {
-			input: "go",
-			tags:  []string{"go"},
-		},
-		"only file": {
-			input: " file=main.go",
-			file:  "main.go",
-		},
-		"tag and file": {
-			input: "python file=script.py",
-			file:  "script.py",
-			tags:  []string{"python"},
-		},
-		"tag, file and tags": {
-			input: "js file=app.js tag1 tag2",
-			file:  "app.js",
-			tags:  []string{"js", "tag1", "tag2"},
-		},
-		"file and tags": {
-			input: " file=config.yaml prod debug",
-			file:  "config.yaml",
-			tags:  []string{"prod", "debug"},
-		},
-		"tags": {
-			input: "ruby test unit",
-			tags:  []string{"ruby", "test", "unit"},
-		},
-		"multiple file tags": {
-			input: " file=first.txt file=second.txt tagA",
-			file:  "second.txt",
-			tags:  []string{"tagA"},
-		},
-		"file with path": {
-			input: "go file=pkg/main.go",
-			file:  "pkg/main.go",
-			tags:  []string{"go"},
-		},
-		"file with spaces in name": {
-			input: "file=my file.txt",
-			file:  "my",
-			tags:  []string{"file.txt"},
-		},
-		"empty file tag": {
-			input: "file=",
-			file:  "",
-		},
-		"only file prefix": {
-			input: "file",
-			tags:  []string{"file"},
-		},
-	}
-
-	for title, cas := range cases {
-		t.Run(title, func(t *testing.T) {
-			t.Parallel()
-
-			file, tags := parseFileTag([]byte(cas.input))
-			assert.Equal(t, cas.file, file)
-			assert.Equal(t, cas.tags, tags)
-		})
-	}
-}
-
 func TestMulti_ExtractFromFile(t *testing.T) {
 	t.Parallel()
 
@@ -233,6 +159,13 @@ func TestMulti_Extract_TableDriven(t *testing.T) {
 				"scripts/build.sh": "echo build\n",
 			},
 		},
+		"pandoc attributes": {
+			multi: &Multi{Single: Single{Tags: []string{"python"}}},
+			input: "```{.python #setup file=run.py}\nprint(1)\n```\n```{r, file=run.R}\n1\n```",
+			expected: map[string]string{
+				"run.py": "print(1)\n",
+			},
+		},
 		"multiple files with duplicates": {
 			multi: &Multi{},
 			input: "```go file=one.go\nfirst\n```\n```go file=two.go\nsecond\n```\n```go file=one.go\nthird\n```",
@@ -254,19 +187,67 @@ func TestMulti_Extract_TableDriven(t *testing.T) {
 	}
 }
 
+func TestMulti_ExtractFromReader(t *testing.T) {
+	t.Parallel()
+
+	m := &Multi{}
+
+	result, err := m.ExtractFromReader(strings.NewReader("```go file=main.go\npackage main\n```\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"main.go": "package main\n"}, result)
+}
+
+func TestMulti_Files(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```sh file=install.sh mode=0755",
+		"echo install",
+		"```",
+		"```yaml file=config.yaml",
+		"a: b",
+		"```",
+		"```sh file=install.sh name=second",
+		"echo done",
+		"```",
+		"```sh",
+		"echo ignored",
+		"```",
+	}, "\n")
+
+	blocks, err := Blocks([]byte(input))
+	require.NoError(t, err)
+
+	files, err := (&Multi{}).Files(blocks)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "install.sh", files[0].Name)
+	assert.Len(t, files[0].Blocks, 2)
+	assert.Equal(t, map[string]string{
+		"file": "install.sh",
+		"mode": "0755",
+		"name": "second",
+	}, files[0].Attributes())
+
+	assert.Equal(t, "config.yaml", files[1].Name)
+	assert.Len(t, files[1].Blocks, 1)
+}
+
 func TestMulti_ExtractFromFileAndWrite_Successful(t *testing.T) {
 	t.Parallel()
 
 	tmpDir := t.TempDir()
 
-	// Create a test markdown file with absolute path for output
+	// Create a test markdown file with a path relative to the output
+	// directory
 	outputFile := filepath.Join(tmpDir, "test.go")
-	mdContent := []byte("```go file=" + outputFile + "\npackage main\n```")
+	mdContent := []byte("```go file=test.go\npackage main\n```")
 	mdFile := filepath.Join(tmpDir, "test.md")
 	err := os.WriteFile(mdFile, mdContent, 0600)
 	require.NoError(t, err)
 
-	multi := &Multi{}
+	multi := &Multi{OutputDir: tmpDir}
 	err = multi.ExtractFromFileAndWrite(mdFile)
 	require.NoError(t, err)
 
@@ -283,12 +264,12 @@ func TestMulti_ExtractFromFileAndWrite_MultipleFiles(t *testing.T) {
 
 	outputFile1 := filepath.Join(tmpDir, "one.go")
 	outputFile2 := filepath.Join(tmpDir, "two.go")
-	mdContent := []byte("```go file=" + outputFile1 + "\nfirst\n```\n```go file=" + outputFile2 + "\nsecond\n```")
+	mdContent := []byte("```go file=one.go\nfirst\n```\n```go file=two.go\nsecond\n```")
 	mdFile := filepath.Join(tmpDir, "test.md")
 	err := os.WriteFile(mdFile, mdContent, 0600)
 	require.NoError(t, err)
 
-	multi := &Multi{}
+	multi := &Multi{OutputDir: tmpDir}
 	err = multi.ExtractFromFileAndWrite(mdFile)
 	require.NoError(t, err)
 
@@ -300,3 +281,17 @@ func TestMulti_ExtractFromFileAndWrite_MultipleFiles(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "second\n", string(content2))
 }
+
+func TestMulti_FlagSet_FileMode(t *testing.T) {
+	t.Parallel()
+
+	for args, expected := range map[string]uint32{
+		"":                0,
+		"-file-mode 0640": 0o640,
+		"-file-mode 755":  0o755,
+	} {
+		multi := &Multi{}
+		require.NoError(t, multi.FlagSet().Parse(strings.Fields(args)), args)
+		assert.Equal(t, expected, multi.FileMode, args)
+	}
+}
@@ -0,0 +1,169 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnknownReference is returned for references to a name no code
+	// block has.
+	ErrUnknownReference = errors.New("no code block with that name")
+	// ErrReferenceCycle is returned if code blocks reference each other.
+	ErrReferenceCycle = errors.New("reference cycle")
+)
+
+// referencePattern matches reference lines like "<<name>>" or
+// "// @include name". The comment can be any of "//", "#", "--" or ";".
+var referencePattern = regexp.MustCompile(`^([ \t]*)(?:<<([^<>\s]+)>>|(?://|#|--|;)[ \t]*@include[ \t]+(\S+))[ \t]*$`)
+
+// expander expands references in code blocks.
+type expander struct {
+	names      map[string][]Block
+	stack      []string
+	referenced map[string]bool
+	// substitute returns the content of a code block before its
+	// references are expanded, see Template.substituter.
+	substitute func(Block) (string, error)
+}
+
+func newExpander(blocks []Block, substitute func(Block) (string, error)) *expander {
+	e := &expander{
+		names:      map[string][]Block{},
+		referenced: map[string]bool{},
+		substitute: substitute,
+	}
+
+	for _, block := range blocks {
+		if name := block.Name(); name != "" {
+			e.names[name] = append(e.names[name], block)
+		}
+	}
+
+	return e
+}
+
+// expand returns the content of block with all references replaced by
+// the expanded contents of the referenced code blocks.
+func (e *expander) expand(block Block) (string, error) {
+	if name := block.Name(); name != "" {
+		e.stack = append(e.stack, name)
+		defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+	}
+
+	content, err := e.substitute(block)
+	if err != nil {
+		return "", err
+	}
+
+	builder := &strings.Builder{}
+	line := block.contentLine()
+
+	for text := range strings.Lines(content) {
+		m := referencePattern.FindStringSubmatch(strings.TrimSuffix(text, "\n"))
+		if m == nil {
+			builder.WriteString(text)
+			line++
+
+			continue
+		}
+
+		name := m[2] + m[3]
+		e.referenced[name] = true
+
+		targets, ok := e.names[name]
+		if !ok {
+			return "", fmt.Errorf("%s:%d: reference %q: %w", block.Source, line, name, ErrUnknownReference)
+		}
+
+		if slices.Contains(e.stack, name) {
+			cycle := append(slices.Clone(e.stack[slices.Index(e.stack, name):]), name)
+			return "", fmt.Errorf("%s:%d: %w: %s", block.Source, line, ErrReferenceCycle, strings.Join(cycle, " -> "))
+		}
+
+		for _, target := range targets {
+			content, err := e.expand(target)
+			if err != nil {
+				return "", err
+			}
+
+			builder.WriteString(indent(content, m[1]))
+		}
+
+		line++
+	}
+
+	return builder.String(), nil
+}
+
+// indent prefixes each non-empty line of s with prefix and ensures
+// that s ends with a newline.
+func indent(s, prefix string) string {
+	builder := &strings.Builder{}
+
+	for line := range strings.Lines(s) {
+		if strings.TrimSpace(line) != "" {
+			builder.WriteString(prefix)
+		}
+
+		builder.WriteString(line)
+	}
+
+	if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// expandReferences replaces reference lines in the selected blocks
+// with the contents of the code blocks they reference, if
+// ExpandReferences is set. The contents of all blocks are passed
+// through substitute first.
+//
+// A reference is a line consisting of "<<name>>" or a comment like
+// "// @include name" and is replaced with the concatenated contents of
+// all code blocks with that name in blocks, see Block.Name. The
+// indentation of the reference is added to each line. References are
+// expanded recursively.
+//
+// Selected blocks that are referenced by another selected block are
+// only included through the reference.
+func (single Single) expandReferences(
+	blocks, selected []Block, substitute func(Block) (string, error),
+) ([]Block, error) {
+	ret := make([]Block, 0, len(selected))
+
+	if !single.ExpandReferences {
+		for _, block := range selected {
+			content, err := substitute(block)
+			if err != nil {
+				return nil, err
+			}
+
+			block.Content = content
+			ret = append(ret, block)
+		}
+
+		return ret, nil
+	}
+
+	e := newExpander(blocks, substitute)
+
+	for _, block := range selected {
+		content, err := e.expand(block)
+		if err != nil {
+			return nil, err
+		}
+
+		block.Content = content
+		ret = append(ret, block)
+	}
+
+	return slices.DeleteFunc(ret, func(block Block) bool {
+		return block.Name() != "" && e.referenced[block.Name()]
+	}), nil
+}
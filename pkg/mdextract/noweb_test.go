@@ -0,0 +1,128 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingle_ExpandReferences(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		single   Single
+		input    string
+		expected string
+		err      error
+	}{
+		"disabled": {
+			single:   Single{Tags: []string{"ci"}},
+			input:    "```go ci\n<<imports>>\n```\n",
+			expected: "<<imports>>\n",
+		},
+		"noweb": {
+			single: Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input: "```go ci\npackage main\n\n<<imports>>\n```\n\n" +
+				"```go name=imports\nimport \"fmt\"\n```\n\n" +
+				"```go name=imports\nimport \"os\"\n```\n",
+			expected: "package main\n\nimport \"fmt\"\nimport \"os\"\n",
+		},
+		"include comment keeps indentation": {
+			single: Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input: "```go ci\nfunc handler() {\n\t// @include handler-body\n}\n```\n\n" +
+				"```go name=handler-body\nif err != nil {\n\treturn\n}\n\nrun()\n```\n",
+			expected: "func handler() {\n\tif err != nil {\n\t\treturn\n\t}\n\n\trun()\n}\n",
+		},
+		"hash include": {
+			single:   Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input:    "```sh ci\n  # @include body\n```\n\n```sh name=body\necho hi\n```\n",
+			expected: "  echo hi\n",
+		},
+		"recursive": {
+			single: Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input: "```go ci\n<<a>>\n```\n\n" +
+				"```go name=a\n  <<b>>\n```\n\n" +
+				"```go name=b\nb\n```\n",
+			expected: "  b\n",
+		},
+		"referenced blocks are not repeated": {
+			single: Single{Tags: []string{"go"}, ExpandReferences: true},
+			input: "```go\nmain\n<<a>>\n```\n\n" +
+				"```go name=a\na\n```\n",
+			expected: "main\na\n",
+		},
+		"reference in text": {
+			single:   Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input:    "```sh ci\necho '<<a>>'\n```\n",
+			expected: "echo '<<a>>'\n",
+		},
+		"missing": {
+			single: Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input:  "```go ci\n<<missing>>\n```\n",
+			err:    ErrUnknownReference,
+		},
+		"cycle": {
+			single: Single{Tags: []string{"ci"}, ExpandReferences: true},
+			input: "```go ci\n<<a>>\n```\n\n" +
+				"```go name=a\n<<b>>\n```\n\n" +
+				"```go name=b\n<<a>>\n```\n",
+			err: ErrReferenceCycle,
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := BlocksFromReader("doc.md", strings.NewReader(tc.input))
+			require.NoError(t, err)
+
+			extracted, err := tc.single.extract(blocks)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, extracted)
+		})
+	}
+}
+
+func TestSingle_ExpandReferences_Errors(t *testing.T) {
+	t.Parallel()
+
+	single := Single{ExpandReferences: true}
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader("```go\npackage main\n<<missing>>\n```\n"))
+	require.NoError(t, err)
+
+	_, err = single.extract(blocks)
+	require.EqualError(t, err, `doc.md:3: reference "missing": no code block with that name`)
+
+	blocks, err = BlocksFromReader("doc.md", strings.NewReader("```go name=a\n<<b>>\n```\n\n```go name=b\n\n<<a>>\n```\n"))
+	require.NoError(t, err)
+
+	_, err = single.extract(blocks)
+	require.EqualError(t, err, "doc.md:7: reference cycle: a -> b -> a")
+}
+
+func TestMulti_ExpandReferences(t *testing.T) {
+	t.Parallel()
+
+	input := "```go file=main.go\npackage main\n\n<<imports>>\n\nfunc main() {\n\t<<body>>\n}\n```\n\n" +
+		"```go name=imports\nimport \"fmt\"\n```\n\n" +
+		"```go name=body\nfmt.Println(\"hi\")\n```\n"
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(input))
+	require.NoError(t, err)
+
+	multi := &Multi{Single: Single{ExpandReferences: true}}
+	extracted, err := multi.extract(blocks)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n",
+	}, extracted)
+}
@@ -0,0 +1,144 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnknownDependency is returned for "requires" attributes
+	// referencing a name no code block has.
+	ErrUnknownDependency = errors.New("no code block with that name")
+	// ErrDependencyCycle is returned if code blocks require each other.
+	ErrDependencyCycle = errors.New("dependency cycle")
+)
+
+// Name returns the name of the block, which is the "name" attribute or
+// the ID if it has none.
+func (block Block) Name() string {
+	if name := block.Attributes["name"]; name != "" {
+		return name
+	}
+
+	return block.ID
+}
+
+// Requires returns the names listed in the comma separated "requires"
+// attribute.
+func (block Block) Requires() []string {
+	ret := []string{}
+
+	for name := range strings.SplitSeq(block.Attributes["requires"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
+
+// location returns the position of the block as file:line.
+func (block Block) location() string {
+	return fmt.Sprintf("%s:%d", block.Source, block.StartLine)
+}
+
+type visitState int
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+// resolver orders code blocks after the code blocks they require.
+type resolver struct {
+	blocks []Block
+	names  map[string][]int
+	// include reports whether a required code block is included,
+	// code blocks that are not included are not followed either. nil
+	// includes all code blocks.
+	include func(Block) bool
+
+	state []visitState
+	path  []int
+	order []int
+}
+
+// resolve returns the indices of the selected blocks and all blocks
+// they require transitively, ordered so that each block follows the
+// blocks it requires. Otherwise the order of selected and the document
+// order of required blocks is kept.
+//
+// Required blocks are looked up by name in all blocks, regardless of
+// whether they match any criteria.
+func resolve(blocks []Block, selected []int, include func(Block) bool) ([]int, error) {
+	r := &resolver{
+		blocks:  blocks,
+		names:   map[string][]int{},
+		include: include,
+		state:   make([]visitState, len(blocks)),
+	}
+
+	for i, block := range blocks {
+		if name := block.Name(); name != "" {
+			r.names[name] = append(r.names[name], i)
+		}
+	}
+
+	for _, i := range selected {
+		if err := r.visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.order, nil
+}
+
+func (r *resolver) visit(i int) error {
+	switch r.state[i] {
+	case visited:
+		return nil
+	case visiting:
+		return r.cycle(i)
+	case unvisited:
+	}
+
+	r.state[i] = visiting
+	r.path = append(r.path, i)
+
+	for _, name := range r.blocks[i].Requires() {
+		deps, ok := r.names[name]
+		if !ok {
+			return fmt.Errorf("%s: requires %q: %w", r.blocks[i].location(), name, ErrUnknownDependency)
+		}
+
+		for _, dep := range deps {
+			if r.include != nil && !r.include(r.blocks[dep]) {
+				continue
+			}
+
+			if err := r.visit(dep); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.path = r.path[:len(r.path)-1]
+	r.state[i] = visited
+	r.order = append(r.order, i)
+
+	return nil
+}
+
+// cycle returns the error for the cycle from block i back to itself.
+func (r *resolver) cycle(i int) error {
+	steps := []string{}
+
+	for _, j := range append(slices.Clone(r.path[slices.Index(r.path, i):]), i) {
+		steps = append(steps, fmt.Sprintf("%s (%s)", r.blocks[j].Name(), r.blocks[j].location()))
+	}
+
+	return fmt.Errorf("%s: %w: %s", r.blocks[i].location(), ErrDependencyCycle, strings.Join(steps, " -> "))
+}
@@ -0,0 +1,144 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlock_Requires(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"setup", "db"}, Block{Attributes: map[string]string{"requires": "setup, db,"}}.Requires())
+	assert.Empty(t, Block{}.Requires())
+}
+
+func TestBlock_Name(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "setup", Block{Attributes: map[string]string{"name": "setup"}, ID: "id"}.Name())
+	assert.Equal(t, "id", Block{ID: "id"}.Name())
+	assert.Empty(t, Block{}.Name())
+}
+
+func TestSingle_Resolve(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		single   Single
+		input    string
+		expected string
+		err      error
+	}{
+		"document order": {
+			single:   Single{Tags: []string{"sh"}},
+			input:    "```sh\none\n```\n\n```sh\ntwo\n```\n",
+			expected: "one\ntwo\n",
+		},
+		"required block first": {
+			single: Single{Tags: []string{"ci"}},
+			input: "```sh ci requires=setup\nrun\n```\n\n" +
+				"```sh name=setup requires=db\nsetup\n```\n\n" +
+				"```sh name=db\ndb\n```\n",
+			expected: "db\nsetup\nrun\n",
+		},
+		"required block in comment": {
+			single:   Single{Tags: []string{"ci"}, Visibility: []Visibility{VisibilityVisible}},
+			input:    "```sh ci requires=setup\nrun\n```\n\n<!--\n```sh name=setup\nsetup\n```\n-->\n",
+			expected: "setup\nrun\n",
+		},
+		"required once": {
+			single: Single{Tags: []string{"ci"}},
+			input: "```sh name=setup\nsetup\n```\n\n" +
+				"```sh ci requires=setup\none\n```\n\n" +
+				"```sh ci requires=setup\ntwo\n```\n",
+			expected: "setup\none\ntwo\n",
+		},
+		"same name": {
+			single: Single{Tags: []string{"ci"}},
+			input: "```sh ci requires=setup\nrun\n```\n\n" +
+				"```sh name=setup\na\n```\n\n" +
+				"```sh name=setup\nb\n```\n",
+			expected: "a\nb\nrun\n",
+		},
+		"pandoc id": {
+			single:   Single{Tags: []string{"ci"}},
+			input:    "```{.sh .ci requires=setup}\nrun\n```\n\n```{.sh #setup}\nsetup\n```\n",
+			expected: "setup\nrun\n",
+		},
+		"unknown": {
+			single: Single{Tags: []string{"ci"}},
+			input:  "```sh ci requires=missing\nrun\n```\n",
+			err:    ErrUnknownDependency,
+		},
+		"cycle": {
+			single: Single{Tags: []string{"ci"}},
+			input: "```sh ci name=a requires=b\na\n```\n\n" +
+				"```sh name=b requires=a\nb\n```\n",
+			err: ErrDependencyCycle,
+		},
+		"self cycle": {
+			single: Single{Tags: []string{"ci"}},
+			input:  "```sh ci name=a requires=a\na\n```\n",
+			err:    ErrDependencyCycle,
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := BlocksFromReader("doc.md", strings.NewReader(tc.input))
+			require.NoError(t, err)
+
+			extracted, err := tc.single.extract(blocks)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, extracted)
+		})
+	}
+}
+
+func TestSingle_Resolve_Errors(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(
+		"```sh name=a requires=b\na\n```\n\n```sh name=b requires=a\nb\n```\n"))
+	require.NoError(t, err)
+
+	_, err = Single{}.Resolve(blocks)
+	require.EqualError(t, err, "doc.md:1: dependency cycle: a (doc.md:1) -> b (doc.md:5) -> a (doc.md:1)")
+
+	blocks, err = BlocksFromReader("doc.md", strings.NewReader("\n```sh requires=missing\na\n```\n"))
+	require.NoError(t, err)
+
+	_, err = Single{}.Resolve(blocks)
+	require.EqualError(t, err, `doc.md:2: requires "missing": no code block with that name`)
+}
+
+func TestMulti_Files_Requires(t *testing.T) {
+	t.Parallel()
+
+	input := "```go file=main.go requires=imports\nfunc main() {}\n```\n\n" +
+		"```go name=imports requires=header\nimport \"fmt\"\n```\n\n" +
+		"```go name=header\npackage main\n```\n\n" +
+		"```sh file=run.sh requires=build\ngo run .\n```\n\n" +
+		"```sh file=build.sh name=build\ngo build\n```\n"
+
+	blocks, err := BlocksFromReader("doc.md", strings.NewReader(input))
+	require.NoError(t, err)
+
+	extracted, err := (&Multi{}).extract(blocks)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"main.go":  "package main\nimport \"fmt\"\nfunc main() {}\n",
+		"run.sh":   "go run .\n",
+		"build.sh": "go build\n",
+	}, extracted)
+}
@@ -0,0 +1,468 @@
+package mdextract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterpreters are the commands used to execute code blocks by
+// language. The path of a file containing the code block is appended
+// to the command.
+var DefaultInterpreters = map[string][]string{
+	"sh":         {"sh", "-e"},
+	"shell":      {"sh", "-e"},
+	"bash":       {"bash", "-e"},
+	"zsh":        {"zsh", "-e"},
+	"python":     {"python3"},
+	"python3":    {"python3"},
+	"py":         {"python3"},
+	"go":         {"go", "run"},
+	"golang":     {"go", "run"},
+	"js":         {"node"},
+	"javascript": {"node"},
+	"ruby":       {"ruby"},
+	"rb":         {"ruby"},
+	"perl":       {"perl"},
+}
+
+// shellLanguages are the languages that can be run in a persistent
+// session.
+var shellLanguages = map[string]bool{
+	"sh":    true,
+	"shell": true,
+	"bash":  true,
+	"zsh":   true,
+}
+
+// languageExtensions are the file extensions used for the files passed
+// to interpreters. Some interpreters like "go run" require them.
+var languageExtensions = map[string]string{
+	"go":         ".go",
+	"golang":     ".go",
+	"python":     ".py",
+	"python3":    ".py",
+	"py":         ".py",
+	"js":         ".js",
+	"javascript": ".js",
+	"ruby":       ".rb",
+	"rb":         ".rb",
+	"perl":       ".pl",
+}
+
+var (
+	// ErrNoInterpreter is returned for code blocks whose language has
+	// no interpreter configured.
+	ErrNoInterpreter = errors.New("no interpreter for language")
+	// ErrSessionEnded is returned for code blocks that were not run
+	// because the shell session exited in an earlier code block.
+	ErrSessionEnded = errors.New("session ended in an earlier code block")
+	// ErrTimeout is returned for code blocks that exceeded the
+	// timeout.
+	ErrTimeout = errors.New("timeout")
+)
+
+// Runner executes code blocks with an interpreter per language.
+type Runner struct {
+	// Interpreters maps languages to the command used to execute code
+	// blocks of that language. It is merged with and takes precedence
+	// over DefaultInterpreters.
+	Interpreters map[string][]string
+	// Session runs all shell code blocks of a language in one
+	// persistent shell, so state like variables and the working
+	// directory is kept between code blocks. Other languages are
+	// always run isolated.
+	// Default: false
+	Session bool
+	// Timeout is the maximum duration of a single code block.
+	// Default: no timeout
+	Timeout time.Duration
+	// Match is the default match mode for expected outputs.
+	// Default: MatchExact
+	Match MatchMode
+	// Trim ignores leading and trailing blank lines and trailing
+	// whitespace when comparing expected outputs.
+	// Default: false
+	Trim bool
+	// Dir is the working directory for the interpreters. A "cwd"
+	// attribute on a code block is resolved relative to it for code
+	// blocks that are run isolated.
+	// Default: current directory
+	Dir string
+}
+
+// Result is the result of executing a code block.
+type Result struct {
+	// Block is the executed code block.
+	Block Block
+	// Expected is the expected output of the code block, nil if it
+	// has none.
+	Expected *Expectation
+	// Command is the interpreter command.
+	Command []string
+	// Stdout and Stderr are the captured outputs.
+	Stdout string
+	Stderr string
+	// Output is the combined output.
+	Output string
+	// Duration is the time the code block took to execute.
+	Duration time.Duration
+	// Skipped is true if the code block was not executed, Err
+	// contains the reason.
+	Skipped bool
+	// Err is nil if the code block was executed successfully.
+	Err error
+}
+
+// Passed reports whether the code block was executed successfully.
+func (result Result) Passed() bool {
+	return !result.Skipped && result.Err == nil
+}
+
+func (runner Runner) interpreter(lang string) []string {
+	interpreters := maps.Clone(DefaultInterpreters)
+	maps.Copy(interpreters, runner.Interpreters)
+
+	return interpreters[strings.ToLower(lang)]
+}
+
+// Run executes the code blocks in order and yields a result for each.
+// Stopping the iteration ends all running sessions.
+//
+// Code blocks tagged "output" are not executed, their content is the
+// expected stdout of the code block preceding them. The expected output
+// can also be given in an "expect" attribute. The stdout is compared
+// using the Match mode, which can be overridden per code block with
+// the "match" and "trim" attributes.
+func (runner Runner) Run(ctx context.Context, blocks []Block) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		sessions := map[string]*session{}
+
+		defer func() {
+			for _, s := range sessions {
+				s.close()
+			}
+		}()
+
+		for i, block := range blocks {
+			if block.isOutput() {
+				continue
+			}
+
+			result := runner.run(ctx, sessions, block)
+			result.Expected = expectation(blocks, i)
+			runner.check(&result)
+
+			if !yield(result) {
+				return
+			}
+		}
+	}
+}
+
+func (runner Runner) run(ctx context.Context, sessions map[string]*session, block Block) Result {
+	lang := strings.ToLower(block.Language())
+
+	result := Result{
+		Block:   block,
+		Command: runner.interpreter(lang),
+	}
+
+	if len(result.Command) == 0 {
+		result.Skipped = true
+		result.Err = fmt.Errorf("%w %q", ErrNoInterpreter, lang)
+
+		return result
+	}
+
+	if runner.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, runner.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	if runner.Session && shellLanguages[lang] {
+		s, ok := sessions[lang]
+		if !ok {
+			s = &session{command: result.Command, dir: runner.Dir}
+			sessions[lang] = s
+		}
+
+		result.Stdout, result.Stderr, result.Err = s.run(ctx, block.Content)
+		result.Skipped = errors.Is(result.Err, ErrSessionEnded)
+		result.Output = result.Stdout + result.Stderr
+	} else {
+		result.Stdout, result.Stderr, result.Output, result.Err = runner.runIsolated(ctx, lang, result.Command, block)
+	}
+
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent writes.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// runIsolated writes the code block to a temporary file and executes
+// it with the interpreter.
+func (runner Runner) runIsolated(
+	ctx context.Context, lang string, command []string, block Block,
+) (string, string, string, error) {
+	tmpDir, err := os.MkdirTemp("", "mdextract-run-")
+	if err != nil {
+		return "", "", "", err
+	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+	file := filepath.Join(tmpDir, "main"+languageExtensions[lang])
+	if err := os.WriteFile(file, []byte(block.Content), defaultFileMode); err != nil {
+		return "", "", "", err
+	}
+
+	args := append(append([]string{}, command[1:]...), file)
+
+	cmd := exec.CommandContext(ctx, command[0], args...) //nolint:gosec
+	cmd.Dir = filepath.Join(runner.Dir, filepath.FromSlash(block.Attributes["cwd"]))
+	cmd.WaitDelay = time.Second
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+
+	setProcessGroup(cmd)
+
+	var stdout, stderr bytes.Buffer
+
+	combined := &lockedBuffer{}
+	cmd.Stdout = io.MultiWriter(&stdout, combined)
+	cmd.Stderr = io.MultiWriter(&stderr, combined)
+
+	err = cmd.Run()
+	if ctx.Err() != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ErrTimeout
+	}
+
+	return stdout.String(), stderr.String(), combined.String(), err
+}
+
+// session is a persistent shell process that code blocks are written
+// to one after another. After each code block a marker is printed to
+// stdout and stderr to separate the outputs of the code blocks.
+type session struct {
+	command []string
+	dir     string
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+	marker string
+	count  int
+	ended  bool
+}
+
+func (s *session) start() error {
+	token := make([]byte, 8) //nolint:mnd
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	s.marker = "__mdextract_" + hex.EncodeToString(token)
+
+	// the session is not bound to the context of a single code block,
+	// it is killed explicitly on timeouts and closed when done.
+	s.cmd = exec.Command(s.command[0], s.command[1:]...) //nolint:gosec,noctx
+	s.cmd.Dir = s.dir
+	setProcessGroup(s.cmd)
+
+	var err error
+
+	if s.stdin, err = s.cmd.StdinPipe(); err != nil {
+		return err
+	}
+
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, err := s.cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	s.stdout = bufio.NewReader(stdout)
+	s.stderr = bufio.NewReader(stderr)
+
+	return s.cmd.Start()
+}
+
+// script wraps the content of a code block. The content reads stdin
+// from /dev/null so it cannot consume the following code blocks.
+func script(content, marker string) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return "{\n" + content + "} </dev/null\n" +
+		"printf '\\n%s %d\\n' '" + marker + "' \"$?\"\n" +
+		"printf '\\n%s\\n' '" + marker + "' >&2\n"
+}
+
+// readUntil reads from r until the marker line and returns the output
+// before it and the rest of the marker line.
+func readUntil(r *bufio.Reader, marker string) (string, string, error) {
+	builder := &strings.Builder{}
+
+	for {
+		line, err := r.ReadString('\n')
+		if rest, ok := strings.CutPrefix(line, marker); ok {
+			// the marker is preceded by a newline to end unterminated
+			// output
+			return strings.TrimSuffix(builder.String(), "\n"), strings.TrimSpace(rest), nil
+		}
+
+		builder.WriteString(line)
+
+		if err != nil {
+			return builder.String(), "", err
+		}
+	}
+}
+
+func (s *session) run(ctx context.Context, content string) (string, string, error) {
+	if s.ended {
+		return "", "", ErrSessionEnded
+	}
+
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			s.ended = true
+			return "", "", err
+		}
+	}
+
+	s.count++
+	marker := s.marker + "_" + strconv.Itoa(s.count) + ":"
+
+	var (
+		stdout, stderr, status string
+		stdoutErr, stderrErr   error
+		wg                     sync.WaitGroup
+	)
+
+	wg.Go(func() {
+		stdout, status, stdoutErr = readUntil(s.stdout, marker)
+	})
+	wg.Go(func() {
+		stderr, _, stderrErr = readUntil(s.stderr, marker)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// the write may block until the shell read the script, so it runs
+	// concurrently to the readers
+	go func() {
+		_, _ = io.WriteString(s.stdin, script(content, marker))
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.kill()
+		<-done
+		s.ended = true
+
+		return stdout, stderr, ErrTimeout
+	}
+
+	if stdoutErr != nil || stderrErr != nil {
+		// the shell exited, e.g. because a command failed with -e
+		s.ended = true
+
+		return stdout, stderr, s.wait()
+	}
+
+	if status != "0" {
+		return stdout, stderr, fmt.Errorf("exit status %s", status)
+	}
+
+	return stdout, stderr, nil
+}
+
+func (s *session) kill() {
+	if s.cmd != nil && s.cmd.Process != nil {
+		_ = killProcessGroup(s.cmd)
+	}
+}
+
+// wait waits for the shell to exit and returns its exit error.
+func (s *session) wait() error {
+	err := s.cmd.Wait()
+	if err == nil {
+		return errors.New("session exited")
+	}
+
+	return err
+}
+
+func (s *session) close() {
+	if s.cmd == nil || s.cmd.ProcessState != nil {
+		return
+	}
+
+	_ = s.stdin.Close()
+
+	done := make(chan struct{})
+
+	go func() {
+		_ = s.cmd.Wait()
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.kill()
+		<-done
+	}
+}
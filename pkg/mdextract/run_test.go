@@ -0,0 +1,127 @@
+package mdextract
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_Run(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		runner  Runner
+		blocks  []Block
+		outputs []string
+		passed  []bool
+		skipped []bool
+	}{
+		"isolated": {
+			blocks: []Block{
+				{Info: "sh", Content: "x=1\necho out\n"},
+				{Info: "sh", Content: "echo ${x:-unset}\n"},
+			},
+			outputs: []string{"out\n", "unset\n"},
+			passed:  []bool{true, true},
+			skipped: []bool{false, false},
+		},
+		"isolated failure": {
+			blocks: []Block{
+				{Info: "sh", Content: "false\necho not reached\n"},
+				{Info: "sh", Content: "echo next\n"},
+			},
+			outputs: []string{"", "next\n"},
+			passed:  []bool{false, true},
+			skipped: []bool{false, false},
+		},
+		"session keeps state": {
+			runner: Runner{Session: true},
+			blocks: []Block{
+				{Info: "sh", Content: "x=1\necho out\necho err >&2\n"},
+				{Info: "sh", Content: "printf %s \"$x\""},
+				{Info: "sh", Content: "cat\necho after cat\n"},
+			},
+			outputs: []string{"out\nerr\n", "1", "after cat\n"},
+			passed:  []bool{true, true, true},
+			skipped: []bool{false, false, false},
+		},
+		"session failure ends session": {
+			runner: Runner{Session: true},
+			blocks: []Block{
+				{Info: "sh", Content: "echo before\nfalse\necho not reached\n"},
+				{Info: "sh", Content: "echo next\n"},
+			},
+			outputs: []string{"before\n", ""},
+			passed:  []bool{false, false},
+			skipped: []bool{false, true},
+		},
+		"no interpreter": {
+			blocks: []Block{
+				{Info: "yaml", Content: "a: b\n"},
+			},
+			outputs: []string{""},
+			passed:  []bool{false},
+			skipped: []bool{true},
+		},
+		"custom interpreter": {
+			runner: Runner{Interpreters: map[string][]string{"txt": {"cat"}}},
+			blocks: []Block{
+				{Info: "txt", Content: "hello\n"},
+			},
+			outputs: []string{"hello\n"},
+			passed:  []bool{true},
+			skipped: []bool{false},
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			results := slices.Collect(tc.runner.Run(t.Context(), tc.blocks))
+			require.Len(t, results, len(tc.blocks))
+
+			for i, result := range results {
+				assert.Equal(t, tc.outputs[i], result.Output, "block %d", i)
+				assert.Equal(t, tc.passed[i], result.Passed(), "block %d: %v", i, result.Err)
+				assert.Equal(t, tc.skipped[i], result.Skipped, "block %d", i)
+			}
+		})
+	}
+}
+
+func TestRunner_Run_Timeout(t *testing.T) {
+	t.Parallel()
+
+	for _, session := range []bool{false, true} {
+		runner := Runner{Session: session, Timeout: 100 * time.Millisecond}
+		blocks := []Block{{Info: "sh", Content: "sleep 5\n"}}
+
+		start := time.Now()
+
+		for result := range runner.Run(context.Background(), blocks) {
+			require.ErrorIs(t, result.Err, ErrTimeout)
+		}
+
+		assert.Less(t, time.Since(start), 3*time.Second)
+	}
+}
+
+func TestRunner_Run_Stdout(t *testing.T) {
+	t.Parallel()
+
+	for _, session := range []bool{false, true} {
+		runner := Runner{Session: session}
+		blocks := []Block{{Info: "bash", Content: "echo out\necho err >&2\n"}}
+
+		for result := range runner.Run(t.Context(), blocks) {
+			require.NoError(t, result.Err)
+			assert.Equal(t, "out\n", result.Stdout)
+			assert.Equal(t, "err\n", result.Stderr)
+		}
+	}
+}
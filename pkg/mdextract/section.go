@@ -0,0 +1,122 @@
+package mdextract
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// sectionRegexPrefix marks section patterns that are regular
+// expressions.
+const sectionRegexPrefix = "re:"
+
+// filterSection matches blocks nested under a heading, see
+// ParseSection.
+type filterSection struct {
+	pattern  string
+	segments []string
+	regex    *regexp.Regexp
+}
+
+// ParseSection returns a filter matching the blocks in the subtree of
+// the headings matching pattern.
+//
+// pattern is a path of headings separated by "/", e.g.
+// "Install/Linux", that must match consecutive headings the block is
+// nested under. Each segment matches a heading if it is equal to the
+// title ignoring case, matches the title as a glob pattern, e.g.
+// "Install*", or is the GitHub-style anchor of the heading, e.g.
+// "#installing-on-linux". Patterns starting with "re:" are regular
+// expressions that must match the path of a heading, with the titles
+// joined by "/".
+func ParseSection(pattern string) (Filter, error) {
+	filter := filterSection{pattern: pattern}
+
+	if expr, ok := strings.CutPrefix(pattern, sectionRegexPrefix); ok {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("section %q: %w", pattern, err)
+		}
+
+		filter.regex = regex
+
+		return filter, nil
+	}
+
+	filter.segments = strings.Split(strings.Trim(pattern, "/"), "/")
+
+	return filter, nil
+}
+
+func (f filterSection) Match(block Block) bool {
+	if f.regex != nil {
+		for i := range block.Headings {
+			if f.regex.MatchString(strings.Join(block.Headings[:i+1], "/")) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for start := 0; start+len(f.segments) <= len(block.Headings); start++ {
+		if f.matchAt(block.Headings[start:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAt reports whether the segments match the first headings.
+func (f filterSection) matchAt(headings []string) bool {
+	for i, segment := range f.segments {
+		if !matchHeading(segment, headings[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f filterSection) String() string {
+	return "section=" + quoteFilterWord(f.pattern)
+}
+
+// matchHeading reports whether a segment of a section pattern matches
+// the title of a heading.
+func matchHeading(segment, title string) bool {
+	if strings.EqualFold(segment, title) {
+		return true
+	}
+
+	if anchor, ok := strings.CutPrefix(segment, "#"); ok {
+		return anchor == slug(title)
+	}
+
+	if matched, err := path.Match(strings.ToLower(segment), strings.ToLower(title)); err == nil && matched {
+		return true
+	}
+
+	return segment == slug(title)
+}
+
+// slug returns the anchor GitHub generates for a heading: the title
+// in lower case without punctuation and with spaces replaced by
+// hyphens. Suffixes for duplicate headings are not added.
+func slug(title string) string {
+	builder := &strings.Builder{}
+
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case r == ' ':
+			builder.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune(r)
+		}
+	}
+
+	return builder.String()
+}
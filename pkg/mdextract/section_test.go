@@ -0,0 +1,138 @@
+package mdextract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSection(t *testing.T) {
+	t.Parallel()
+
+	headings := []string{"mdextract", "Installation", "Linux (x86_64)", "Ubuntu"}
+
+	cases := map[string]bool{
+		"Installation":              true,
+		"installation":              true,
+		"Install*":                  true,
+		"Installation/Linux*":       true,
+		"Installation/Linux*/":      true,
+		"mdextract/Installation":    true,
+		"Linux*/Ubuntu":             true,
+		"#linux-x86_64":             true,
+		"installation/linux-x86_64": true,
+		"re:^mdextract/Install":     true,
+		"re:Ubuntu$":                true,
+		"Upgrading":                 false,
+		"Ubuntu/Linux*":             false,
+		"Installation/Ubuntu":       false,
+		"#installation-linux":       false,
+		"re:^Installation":          false,
+	}
+
+	for pattern, expected := range cases {
+		t.Run(pattern, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := ParseSection(pattern)
+			require.NoError(t, err)
+			assert.Equal(t, expected, filter.Match(Block{Headings: headings}))
+		})
+	}
+
+	_, err := ParseSection("re:(")
+	require.Error(t, err)
+}
+
+func TestSlug(t *testing.T) {
+	t.Parallel()
+
+	for title, expected := range map[string]string{
+		"Installation":        "installation",
+		"Linux (x86_64)":      "linux-x86_64",
+		"What's new in v1.2?": "whats-new-in-v12",
+		"  Multi   spaces ":   "multi---spaces",
+		"Über-Größe":          "über-größe",
+	} {
+		assert.Equal(t, expected, slug(title), title)
+	}
+}
+
+func TestSingle_Extract_Section(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"# Install",
+		"",
+		"## Linux",
+		"",
+		"```sh",
+		"apt install mdextract",
+		"```",
+		"",
+		"### Arch",
+		"",
+		"```sh",
+		"pacman -S mdextract",
+		"```",
+		"",
+		"## macOS",
+		"",
+		"```sh",
+		"brew install mdextract",
+		"```",
+		"",
+		"# Upgrading",
+		"",
+		"```sh",
+		"mdextract upgrade",
+		"```",
+		"",
+	}, "\n")
+
+	cases := map[string]struct {
+		sections []string
+		filter   string
+		expected string
+	}{
+		"subtree": {
+			sections: []string{"Install/Linux"},
+			expected: "apt install mdextract\npacman -S mdextract\n",
+		},
+		"multiple": {
+			sections: []string{"Arch", "#upgrading"},
+			expected: "pacman -S mdextract\nmdextract upgrade\n",
+		},
+		"filter": {
+			filter:   "sh && section=Install && section!=Linux",
+			expected: "brew install mdextract\n",
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			single := Single{}
+
+			fs := single.FlagSet()
+			args := []string{}
+
+			for _, section := range cas.sections {
+				args = append(args, "-section", section)
+			}
+
+			if cas.filter != "" {
+				args = append(args, "-filter", cas.filter)
+			}
+
+			require.NoError(t, fs.Parse(args))
+
+			extracted, err := single.Extract([]byte(input))
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, extracted)
+		})
+	}
+}
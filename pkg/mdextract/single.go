@@ -1,14 +1,12 @@
 package mdextract
 
 import (
-	"bytes"
 	"flag"
-	"os"
+	"fmt"
+	"io"
 	"slices"
+	"strconv"
 	"strings"
-
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/ast"
 )
 
 // Single goes through a markdown document and extracts code blocks
@@ -24,12 +22,67 @@ type Single struct {
 	// a codeblock has both a tag in Tags and ExcludeTags, it will be
 	// excluded.
 	ExcludeTags []string
-	// ExcludeComments disables extracting code blocks inside HTML
-	// comments.
+	// Filter allows filtering code blocks with a boolean expression,
+	// see ParseFilter. Filter is combined with Tags and ExcludeTags,
+	// a code block must match all of them to be extracted.
+	Filter Filter
+	// Section restricts extraction to code blocks nested under
+	// matching headings, see ParseSection. Section is combined with
+	// the other criteria.
+	Section Filter
+	// Visibility are the visibilities of the code blocks to extract,
+	// e.g. to exclude code blocks inside HTML comments or to include
+	// code blocks inside <details> elements.
+	// Default: DefaultVisibility
+	Visibility []Visibility
+	// LineDirectives prefixes each code block with a marker pointing
+	// at the markdown file and line the block content starts on, e.g.
+	// "//line README.md:42" for Go or "# README.md:42" for shell.
+	// Languages without known comment syntax get no marker.
+	// Default: false
+	LineDirectives bool
+	// Headers prefixes each code block with a comment naming its
+	// markdown source, line and tags, e.g.
+	// "# --- README.md:42 (bash ci) ---". Languages without known
+	// comment syntax get no header.
+	// Default: false
+	Headers bool
+	// Separator is a text/template written between concatenated code
+	// blocks, with the following block as data, e.g.
+	// "\n# {{ .Source }}\n". Each code block starts on its own line
+	// regardless of the separator.
+	// Default: ""
+	Separator string
+	// ExpandReferences replaces reference lines like "<<name>>" or
+	// "// @include name" with the contents of the code blocks with
+	// that name.
+	// Default: false
+	ExpandReferences bool
+	// Template configures the substitution of variables in the
+	// contents of code blocks.
+	Template Template
+	// Strict fails on problems in the markdown documents like
+	// unterminated code fences, unknown attributes or criteria that
+	// match no code blocks. Otherwise they are written as warnings to
+	// Warnings.
 	// Default: false
-	ExcludeComments bool
+	Strict bool
+	// Warnings receives the problems found in non-strict mode.
+	// Default: os.Stderr
+	Warnings io.Writer
+	// KnownAttributes are attributes besides the ones used by
+	// mdextract that are not reported as unknown, e.g. attributes
+	// used in filters.
+	KnownAttributes []string
+	// SourceName is the name of markdown data that is not read from
+	// a file, e.g. with Extract or ExtractFromReader. It is used in
+	// error messages and line directives.
+	SourceName string
 }
 
+// separatorEscapes unescapes separators given on the command line.
+var separatorEscapes = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+
 func split(s string) []string {
 	if len(s) == 0 {
 		return []string{}
@@ -40,6 +93,26 @@ func split(s string) []string {
 
 // FlagSet returns the flag set for the Single struct.
 func (single *Single) FlagSet() *flag.FlagSet {
+	fs := single.ProcessFlagSet()
+	single.contentFlags(fs)
+
+	return fs
+}
+
+// ProcessFlagSet returns the flag set for the Single struct without the
+// flags for line directives, headers and separators, for commands that
+// process code blocks individually instead of concatenating them.
+func (single *Single) ProcessFlagSet() *flag.FlagSet {
+	fs := single.SelectFlagSet()
+	single.processFlags(fs)
+
+	return fs
+}
+
+// SelectFlagSet returns the flag set for the Single struct with only
+// the flags selecting code blocks and reporting problems, for commands
+// that do not use the processed contents of code blocks.
+func (single *Single) SelectFlagSet() *flag.FlagSet {
 	fs := flag.NewFlagSet("single", flag.ExitOnError)
 	fs.Func("tags", "Tags to filter code blocks, comma-separated", func(s string) error {
 		single.Tags = split(s)
@@ -49,97 +122,309 @@ func (single *Single) FlagSet() *flag.FlagSet {
 		single.ExcludeTags = split(s)
 		return nil
 	})
-	fs.BoolVar(&single.ExcludeComments, "exclude-comments", false, "Exclude code blocks inside HTML comments")
+	fs.Func("filter", "Filter expression for code blocks, e.g. 'bash && (ci || smoke) && !slow'", func(s string) error {
+		filter, err := ParseFilter(s)
+		if err != nil {
+			return err
+		}
+
+		single.Filter = filter
+
+		return nil
+	})
+	// repeatable flags replace the values set before parsing, e.g. by
+	// a profile, on their first occurrence
+	sectionSet, knownAttributesSet := false, false
+
+	fs.Func("section", "Only extract code blocks under headings matching a path like 'Install/Linux', a glob, "+
+		"an anchor like '#install' or 're:' and a regular expression, can be repeated", func(s string) error {
+		if !sectionSet {
+			single.Section, sectionSet = nil, true
+		}
+
+		if s == "" {
+			return nil
+		}
+
+		section, err := ParseSection(s)
+		if err != nil {
+			return err
+		}
+
+		sections, _ := single.Section.(filterOr)
+		single.Section = append(sections, section)
+
+		return nil
+	})
+	fs.Func("visibility", "Visibilities of code blocks to extract, comma-separated: visible, comment, details or all "+
+		"(default visible,comment)", func(s string) error {
+		if s == "" {
+			single.Visibility = nil
+			return nil
+		}
+
+		visibility, err := ParseVisibility(s)
+		if err != nil {
+			return err
+		}
+
+		single.Visibility = visibility
+
+		return nil
+	})
+	fs.BoolFunc("exclude-comments", "Exclude code blocks inside HTML comments, "+
+		"short for removing comment from -visibility", func(s string) error {
+		exclude, err := strconv.ParseBool(s)
+		if err != nil || !exclude {
+			return err
+		}
+
+		single.Visibility = slices.DeleteFunc(slices.Clone(single.visibility()), func(v Visibility) bool {
+			return v == VisibilityComment
+		})
+
+		return nil
+	})
+	fs.StringVar(&single.SourceName, "stdin-name", "stdin",
+		"Name of markdown read from stdin in error messages and line directives")
+	fs.BoolVar(&single.Strict, "strict", false, "Fail on problems like unterminated code fences, unknown attributes "+
+		"or criteria matching no code blocks instead of warning")
+	fs.Func("known-attributes", "Attributes not reported as unknown, "+
+		"comma-separated, can be repeated", func(s string) error {
+		if !knownAttributesSet {
+			single.KnownAttributes, knownAttributesSet = nil, true
+		}
+
+		single.KnownAttributes = append(single.KnownAttributes, split(s)...)
+
+		return nil
+	})
 
 	return fs
 }
 
+// contentFlags adds the flags for the concatenated contents, i.e. line
+// directives, headers and separators, to fs.
+func (single *Single) contentFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&single.LineDirectives, "line-directives", false,
+		"Prefix code blocks with markers pointing at their markdown source")
+	fs.BoolVar(&single.Headers, "headers", false,
+		"Prefix code blocks with a comment naming their markdown source, line and tags")
+	fs.Func("separator", `Template written between code blocks, \n and \t are unescaped, `+
+		`e.g. '\n# {{ .Source }}\n'`, func(s string) error {
+		s = separatorEscapes.Replace(s)
+		if _, err := parseSeparator(s); err != nil {
+			return err
+		}
+
+		single.Separator = s
+
+		return nil
+	})
+}
+
+// processFlags adds the flags for expanding references and templating
+// to fs.
+func (single *Single) processFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&single.ExpandReferences, "expand-references", false,
+		"Expand references like <<name>> or // @include name with the contents of the named code blocks")
+	fs.BoolVar(&single.Template.Enabled, "template", false, "Substitute variables like ${NAME} or {{ .NAME }} "+
+		"in all code blocks, not only in those with a template attribute")
+	fs.Func("var", "Variable for templating as NAME=value, can be repeated", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid variable %q, expected NAME=value", s)
+		}
+
+		single.setVar(name, value, true)
+
+		return nil
+	})
+	fs.Func("vars-file", "File with NAME=value lines for templating, -var takes precedence", func(s string) error {
+		if s == "" {
+			return nil
+		}
+
+		vars, err := ReadVarsFile(s)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range vars {
+			single.setVar(name, value, false)
+		}
+
+		return nil
+	})
+	fs.BoolVar(&single.Template.Env, "vars-env", false, "Look up variables without a value in the environment")
+	fs.BoolVar(&single.Template.Strict, "strict-vars", false,
+		"Fail on variables without a value instead of leaving them as is")
+}
+
+// setVar sets a template variable. Existing values are only replaced
+// if overwrite is true.
+func (single *Single) setVar(name, value string, overwrite bool) {
+	if single.Template.Vars == nil {
+		single.Template.Vars = map[string]string{}
+	}
+
+	if _, ok := single.Template.Vars[name]; ok && !overwrite {
+		return
+	}
+
+	single.Template.Vars[name] = value
+}
+
 func parseTag(b []byte) []string {
 	if len(b) == 0 {
 		return []string{}
 	}
 
-	ret := []string{}
+	return splitInfo(string(b))
+}
 
-	for tag := range strings.SplitSeq(string(b), " ") {
-		tag = strings.TrimSpace(tag)
-		if len(tag) == 0 {
-			continue
-		}
+// filter returns the filter combining Tags, ExcludeTags and Filter.
+func (single Single) filter() Filter {
+	filter := filterAnd{TagsFilter(single.Tags, single.ExcludeTags)}
+	if single.Filter != nil {
+		filter = append(filter, single.Filter)
+	}
 
-		ret = append(ret, tag)
+	if single.Section != nil {
+		filter = append(filter, single.Section)
+	}
+
+	return filter
+}
+
+// Select returns the blocks matching the criteria.
+func (single Single) Select(blocks []Block) []Block {
+	ret := []Block{}
+
+	for _, i := range single.selected(blocks) {
+		ret = append(ret, blocks[i])
 	}
 
 	return ret
 }
 
-func (single Single) acceptBlock(tags []string) bool {
-	if len(single.Tags) > 0 {
-		for _, tag := range single.Tags {
-			if !slices.Contains(tags, tag) {
-				return false
-			}
+// selected returns the indices of the blocks matching the criteria.
+// Unterminated blocks are never selected.
+func (single Single) selected(blocks []Block) []int {
+	ret := []int{}
+	filter := single.filter()
+
+	for i, block := range blocks {
+		if !block.Unterminated && single.matches(filter, block) {
+			ret = append(ret, i)
 		}
 	}
 
-	if len(single.ExcludeTags) > 0 {
-		for _, exTag := range single.ExcludeTags {
-			if slices.Contains(tags, exTag) {
-				return false
-			}
-		}
+	return ret
+}
+
+// matches reports whether block matches filter and has one of the
+// visibilities to extract.
+func (single Single) matches(filter Filter, block Block) bool {
+	return slices.Contains(single.visibility(), block.Visibility) && filter.Match(block)
+}
+
+// Resolve returns the blocks matching the criteria and the blocks they
+// require, ordered so that each block follows the blocks it requires.
+//
+// A block requires other blocks by listing their names in the comma
+// separated "requires" attribute, e.g. requires=setup,db. The name of
+// a block is its "name" attribute or its ID. Required blocks are
+// included regardless of the criteria, if multiple blocks have the
+// same name all of them are included. Unknown names and cycles are
+// errors.
+func (single Single) Resolve(blocks []Block) ([]Block, error) {
+	order, err := resolve(blocks, single.selected(blocks), nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	ret := make([]Block, 0, len(order))
+	for _, i := range order {
+		ret = append(ret, blocks[i])
+	}
+
+	return ret, nil
 }
 
 // ExtractFromFile reads a markdown file from the given path and
 // extracts code block contents from it based on the specified tags.
 func (single Single) ExtractFromFile(p string) (string, error) {
-	b, err := os.ReadFile(p) //nolint:gosec
+	blocks, err := BlocksFromFile(p)
 	if err != nil {
 		return "", err
 	}
 
-	return single.Extract(b)
+	return single.extract(blocks)
+}
+
+// ExtractFromReader reads markdown from r and extracts code block
+// contents from it based on the specified tags.
+func (single Single) ExtractFromReader(r io.Reader) (string, error) {
+	blocks, err := BlocksFromReader(single.SourceName, r)
+	if err != nil {
+		return "", err
+	}
+
+	return single.extract(blocks)
 }
 
 // Extract extracts code block contents from the given markdown data
 // based on the specified tags.
 func (single Single) Extract(data []byte) (string, error) {
-	builder := &strings.Builder{}
-	node := markdown.Parse(data, nil)
-
-	ast.WalkFunc(node, ast.NodeVisitorFunc(func(node ast.Node, _ bool) ast.WalkStatus {
-		switch n := node.(type) {
-		case *ast.CodeBlock:
-			tags := parseTag(n.Info)
-			if !single.acceptBlock(tags) {
-				return ast.GoToNext
-			}
-
-			builder.Write(n.Literal)
-		case *ast.HTMLBlock:
-			// an HTML block might be a comment with a code block that
-			// should only be executed in e.g. CI
-			if single.ExcludeComments {
-				return ast.GoToNext
-			}
-			// Strip the comments, parse as markdown and add the code
-			// blocks
-			comment := bytes.TrimPrefix(n.Literal, []byte("<!--"))
-			comment = bytes.TrimSuffix(comment, []byte("-->"))
-
-			content, err := single.Extract(comment)
-			if err != nil {
-				return ast.Terminate
-			}
-
-			builder.WriteString(content)
-		}
+	blocks, err := parseBlocks(single.SourceName, data)
+	if err != nil {
+		return "", err
+	}
+
+	return single.extract(blocks)
+}
+
+// ExtractBlocks returns the concatenated contents of the code blocks
+// matching the criteria and the blocks they require, see Resolve.
+func (single Single) ExtractBlocks(blocks []Block) (string, error) {
+	return single.extract(blocks)
+}
+
+func (single Single) extract(blocks []Block) (string, error) {
+	if err := single.validate(blocks); err != nil {
+		return "", err
+	}
 
-		return ast.GoToNext
-	}))
+	resolved, err := single.Resolve(blocks)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err = single.process(blocks, resolved)
+	if err != nil {
+		return "", err
+	}
+
+	return single.concat(resolved)
+}
+
+// process substitutes variables and expands references in the selected
+// blocks. Variables are substituted in each code block according to its
+// own attributes before it is expanded into another one. Unknown
+// variables are reported like other problems unless Template.Strict is
+// set, see Single.Strict.
+func (single Single) process(blocks, selected []Block) ([]Block, error) {
+	problems := []error{}
+
+	selected, err := single.expandReferences(blocks, selected, single.Template.substituter(&problems))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := single.report(problems); err != nil {
+		return nil, err
+	}
 
-	return builder.String(), nil
+	return selected, nil
 }
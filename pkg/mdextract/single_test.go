@@ -4,12 +4,11 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/gomarkdown/markdown/ast"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestSingle_AcceptBlock(t *testing.T) {
+func TestSingle_Select_Tags(t *testing.T) {
 	t.Parallel()
 
 	cases := map[string]struct {
@@ -54,12 +53,9 @@ func TestSingle_AcceptBlock(t *testing.T) {
 		t.Run(title, func(t *testing.T) {
 			t.Parallel()
 
-			block := &ast.CodeBlock{
-				Info: []byte(cas.info),
-			}
-			tags := parseTag(block.Info)
-			result := cas.single.acceptBlock(tags)
-			require.Equal(t, cas.expected, result)
+			blocks, err := parseBlocks("doc.md", []byte("```"+cas.info+"\necho\n```\n"))
+			require.NoError(t, err)
+			require.Equal(t, cas.expected, len(cas.single.Select(blocks)) == 1)
 		})
 	}
 }
@@ -133,8 +129,8 @@ func TestSingle_ExtractFromFile(t *testing.T) {
 		},
 		"tag ci, excluding comments": {
 			single: Single{
-				Tags:            []string{"ci"},
-				ExcludeComments: true,
+				Tags:       []string{"ci"},
+				Visibility: []Visibility{VisibilityVisible},
 			},
 			expected: []string{
 				"code block with go with tag ci",
@@ -239,6 +235,20 @@ func TestSingle_ExtractFromFile_Error(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestSingle_ExtractFromReader(t *testing.T) {
+	t.Parallel()
+
+	single := Single{
+		Tags:           []string{"ci"},
+		LineDirectives: true,
+		SourceName:     "README.md",
+	}
+
+	result, err := single.ExtractFromReader(strings.NewReader("# title\n\n```go ci\npackage main\n```\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "//line README.md:4\npackage main\n", result)
+}
+
 func TestSingle_Extract_TableDriven(t *testing.T) {
 	t.Parallel()
 
@@ -284,7 +294,7 @@ func TestSingle_Extract_TableDriven(t *testing.T) {
 	}
 }
 
-func TestSingle_AcceptBlock_AdditionalCases(t *testing.T) {
+func TestSingle_Select_TagsAdditionalCases(t *testing.T) {
 	t.Parallel()
 
 	cases := map[string]struct {
@@ -318,8 +328,29 @@ func TestSingle_AcceptBlock_AdditionalCases(t *testing.T) {
 		t.Run(title, func(t *testing.T) {
 			t.Parallel()
 
-			result := cas.single.acceptBlock(cas.tags)
-			assert.Equal(t, cas.expected, result)
+			blocks, err := parseBlocks("doc.md", []byte("```"+strings.Join(cas.tags, " ")+"\necho\n```\n"))
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, len(cas.single.Select(blocks)) == 1)
 		})
 	}
 }
+
+func TestSingle_FlagSets(t *testing.T) {
+	t.Parallel()
+
+	single := &Single{}
+
+	for name, expected := range map[string][3]bool{
+		"tags":              {true, true, true},
+		"strict":            {true, true, true},
+		"expand-references": {false, true, true},
+		"var":               {false, true, true},
+		"line-directives":   {false, false, true},
+		"headers":           {false, false, true},
+		"separator":         {false, false, true},
+	} {
+		assert.Equal(t, expected[0], single.SelectFlagSet().Lookup(name) != nil, name)
+		assert.Equal(t, expected[1], single.ProcessFlagSet().Lookup(name) != nil, name)
+		assert.Equal(t, expected[2], single.FlagSet().Lookup(name) != nil, name)
+	}
+}
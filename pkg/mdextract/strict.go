@@ -0,0 +1,197 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnterminatedFence is reported for fenced code blocks without
+	// a closing fence.
+	ErrUnterminatedFence = errors.New("unterminated code fence")
+	// ErrMalformedFence is reported for fenced code blocks in HTML
+	// comments that are not closed before the end of the comment.
+	ErrMalformedFence = errors.New("code fence in HTML comment not closed before -->")
+	// ErrEmptyFile is reported for empty "file" attributes.
+	ErrEmptyFile = errors.New("empty file attribute")
+	// ErrAttributeConflict is reported for code blocks of the same
+	// file that disagree on the language.
+	ErrAttributeConflict = errors.New("conflicting attributes")
+	// ErrUnknownAttribute is reported for attributes mdextract does
+	// not use.
+	ErrUnknownAttribute = errors.New("unknown attribute")
+	// ErrNoMatch is reported if no code block matches the criteria.
+	ErrNoMatch = errors.New("no code blocks match the criteria")
+)
+
+// builtinAttributes are the attributes used by mdextract.
+var builtinAttributes = []string{
+	"cwd", "expect", "file", "match", "mode", "name", "region", "requires", "source", "template", "trim",
+}
+
+// Error is a problem at a position in a markdown document.
+type Error struct {
+	Source string
+	Line   int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.Source, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// blockError returns an Error at the position of block.
+func blockError(block Block, err error) error {
+	return &Error{Source: block.Source, Line: block.StartLine, Err: err}
+}
+
+// checkBlocks returns the problems of the blocks: unterminated fences,
+// empty "file" attributes and unknown attributes. Attributes of code
+// blocks using the curly brace syntax are not checked as they commonly
+// hold options for other tools.
+func (single Single) checkBlocks(blocks []Block) []error {
+	ret := []error{}
+
+	for _, block := range blocks {
+		if block.Unterminated {
+			if block.Visibility == VisibilityComment {
+				ret = append(ret, blockError(block, ErrMalformedFence))
+			} else {
+				ret = append(ret, blockError(block, ErrUnterminatedFence))
+			}
+		}
+
+		if file, ok := block.Attributes["file"]; ok && strings.TrimSpace(file) == "" {
+			ret = append(ret, blockError(block, ErrEmptyFile))
+		}
+
+		if strings.HasPrefix(block.Info, "{") {
+			continue
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(block.Attributes)) {
+			known := append(slices.Clone(builtinAttributes), single.KnownAttributes...)
+			if slices.Contains(known, key) {
+				continue
+			}
+
+			err := fmt.Errorf("%w %q", ErrUnknownAttribute, key)
+			if suggestion := suggest(key, known); suggestion != "" {
+				err = fmt.Errorf("%w, did you mean %q?", err, suggestion)
+			}
+
+			ret = append(ret, blockError(block, err))
+		}
+	}
+
+	return ret
+}
+
+// fileAttributes are the attributes that apply to the whole file a code
+// block is written to, see checkFiles.
+var fileAttributes = []string{"mode"}
+
+// checkFiles returns the code blocks of a file that disagree with an
+// earlier code block of the file on the language or on an attribute
+// that applies to the whole file, e.g. "mode".
+func checkFiles(files []File) []error {
+	ret := []error{}
+
+	for _, file := range files {
+		var first Block
+
+		seen := map[string]Block{}
+
+		for _, block := range file.Blocks {
+			if block.Attributes["file"] != file.Name {
+				continue
+			}
+
+			if lang := block.Language(); lang != "" {
+				if first.Language() == "" {
+					first = block
+				} else if lang != first.Language() {
+					ret = append(ret, blockError(block, fmt.Errorf("%w: file %q: language %q conflicts with %q at %s",
+						ErrAttributeConflict, file.Name, lang, first.Language(), first.location())))
+				}
+			}
+
+			for _, key := range fileAttributes {
+				if _, ok := block.Attributes[key]; !ok {
+					continue
+				}
+
+				prev, ok := seen[key]
+				if !ok {
+					seen[key] = block
+					continue
+				}
+
+				if value := block.Attributes[key]; value != prev.Attributes[key] {
+					ret = append(ret, blockError(block, fmt.Errorf("%w: file %q: %s=%q conflicts with %q at %s",
+						ErrAttributeConflict, file.Name, key, value, prev.Attributes[key], prev.location())))
+				}
+			}
+		}
+	}
+
+	return ret
+}
+
+// validate reports the problems of the blocks and whether no code
+// block matches the criteria, see Strict.
+func (single Single) validate(blocks []Block) error {
+	problems := single.checkBlocks(blocks)
+	if len(single.selected(blocks)) == 0 {
+		problems = append(problems, ErrNoMatch)
+	}
+
+	return single.report(problems)
+}
+
+// validate reports the problems of the blocks, conflicts between the
+// code blocks of the same file and whether no file is extracted, see
+// Strict.
+func (multi *Multi) validate(blocks []Block, files []File) error {
+	problems := multi.checkBlocks(blocks)
+	problems = append(problems, checkFiles(files)...)
+
+	if len(files) == 0 {
+		problems = append(problems, ErrNoMatch)
+	}
+
+	return multi.report(problems)
+}
+
+// report returns the problems in strict mode. Otherwise they are
+// written as warnings to Warnings.
+func (single Single) report(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if single.Strict {
+		return errors.Join(problems...)
+	}
+
+	w := single.Warnings
+	if w == nil {
+		w = os.Stderr
+	}
+
+	for _, problem := range problems {
+		if _, err := fmt.Fprintf(w, "warning: %v\n", problem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
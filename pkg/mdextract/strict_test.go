@@ -0,0 +1,173 @@
+package mdextract
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingle_Extract_Strict(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		input    []string
+		single   Single
+		expected error
+		line     int
+	}{
+		"valid": {
+			input: []string{"```sh ci", "echo ok", "```"},
+		},
+		"unterminated fence": {
+			input:    []string{"# Title", "", "```sh", "echo ok"},
+			expected: ErrUnterminatedFence,
+			line:     3,
+		},
+		"malformed fence in comment": {
+			input:    []string{"```sh", "echo ok", "```", "", "<!--", "```sh", "echo hidden", "-->"},
+			expected: ErrMalformedFence,
+			line:     6,
+		},
+		"empty file": {
+			input:    []string{"```sh file=", "echo ok", "```"},
+			expected: ErrEmptyFile,
+			line:     1,
+		},
+		"unknown attribute": {
+			input:    []string{"```sh fiel=run.sh", "echo ok", "```"},
+			expected: ErrUnknownAttribute,
+			line:     1,
+		},
+		"known attribute": {
+			input:  []string{"```sh os=linux", "echo ok", "```"},
+			single: Single{KnownAttributes: []string{"os"}},
+		},
+		"curly braces": {
+			input: []string{"```{r setup, eval=FALSE}", "x <- 1", "```"},
+		},
+		"no match": {
+			input:    []string{"```sh", "echo ok", "```"},
+			single:   Single{Tags: []string{"ci"}},
+			expected: ErrNoMatch,
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			input := []byte(strings.Join(cas.input, "\n"))
+
+			warnings := &bytes.Buffer{}
+			single := cas.single
+			single.SourceName = "doc.md"
+			single.Warnings = warnings
+
+			_, err := single.Extract(input)
+			require.NoError(t, err)
+
+			single.Strict = true
+			_, err = single.Extract(input)
+
+			if cas.expected == nil {
+				require.NoError(t, err)
+				assert.Empty(t, warnings.String())
+
+				return
+			}
+
+			require.ErrorIs(t, err, cas.expected)
+			assert.Equal(t, "warning: "+strings.ReplaceAll(err.Error(), "\n", "\nwarning: ")+"\n", warnings.String())
+
+			if cas.line > 0 {
+				var posErr *Error
+				require.ErrorAs(t, err, &posErr)
+				assert.Equal(t, "doc.md", posErr.Source)
+				assert.Equal(t, cas.line, posErr.Line)
+			}
+		})
+	}
+}
+
+func TestMulti_Extract_Strict(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```sh file=setup.sh",
+		"echo one",
+		"```",
+		"",
+		"```python file=setup.sh",
+		"print('two')",
+		"```",
+	}, "\n")
+
+	multi := &Multi{Single: Single{SourceName: "doc.md", Strict: true}}
+	_, err := multi.Extract([]byte(input))
+	require.ErrorIs(t, err, ErrAttributeConflict)
+	require.EqualError(t, err,
+		`doc.md:5: conflicting attributes: file "setup.sh": language "python" conflicts with "sh" at doc.md:1`)
+
+	_, err = multi.Extract([]byte(strings.Join([]string{
+		"```sh file=setup.sh mode=0755 name=one",
+		"echo one",
+		"```",
+		"",
+		"```sh file=setup.sh mode=0700 name=two",
+		"echo two",
+		"```",
+	}, "\n")))
+	require.ErrorIs(t, err, ErrAttributeConflict)
+	require.EqualError(t, err,
+		`doc.md:5: conflicting attributes: file "setup.sh": mode="0700" conflicts with "0755" at doc.md:1`)
+
+	// attributes other than the file attributes may differ per code block
+	multi.KnownAttributes = []string{"os"}
+	_, err = multi.Extract([]byte(strings.Join([]string{
+		"```sh file=setup.sh os=linux",
+		"echo linux",
+		"```",
+		"",
+		"```sh file=setup.sh os=mac",
+		"echo mac",
+		"```",
+	}, "\n")))
+	require.NoError(t, err)
+
+	_, err = multi.Extract([]byte("```sh\necho no file\n```\n"))
+	require.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestSingle_Extract_StrictVariables(t *testing.T) {
+	t.Parallel()
+
+	input := []byte("```sh template\necho ${MISSING}\n```\n")
+
+	warnings := &bytes.Buffer{}
+	single := Single{SourceName: "doc.md", Warnings: warnings}
+
+	out, err := single.Extract(input)
+	require.NoError(t, err)
+	assert.Equal(t, "echo ${MISSING}\n", out)
+	assert.Equal(t, "warning: doc.md:2: unknown variable \"MISSING\"\n", warnings.String())
+
+	single.Strict = true
+	_, err = single.Extract(input)
+	require.ErrorIs(t, err, ErrUnknownVariable)
+	require.EqualError(t, err, `doc.md:2: unknown variable "MISSING"`)
+}
+
+func TestBlocks_NestedError(t *testing.T) {
+	t.Parallel()
+
+	input := "<details>\n\n<!-- mdextract: unknown -->\n\n</details>\n"
+
+	_, err := Single{SourceName: "doc.md"}.Extract([]byte(input))
+	require.ErrorIs(t, err, ErrUnknownDirective)
+	require.True(t, strings.HasPrefix(err.Error(), "doc.md:3:"), err.Error())
+	require.False(t, errors.Is(err, ErrNoMatch))
+}
@@ -0,0 +1,177 @@
+package mdextract
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownVariable is returned in strict mode for placeholders of
+// variables without a value.
+var ErrUnknownVariable = errors.New("unknown variable")
+
+// variablePattern matches the placeholders "${NAME}" and "{{ .NAME }}"
+// as well as the escaped form "$${NAME}".
+var variablePattern = regexp.MustCompile(`\$\$\{[A-Za-z_]\w*\}|\$\{([A-Za-z_]\w*)\}|\{\{\s*\.([A-Za-z_]\w*)\s*\}\}`)
+
+// Template configures the substitution of variables in the contents of
+// code blocks.
+//
+// Placeholders are written as ${NAME} or {{ .NAME }}. $${NAME} is
+// replaced with a literal ${NAME}.
+type Template struct {
+	// Enabled substitutes variables in all code blocks. Otherwise only
+	// code blocks with a "template" tag or "template=true" are
+	// templated. "template=false" disables templating for a code
+	// block.
+	// Default: false
+	Enabled bool
+	// Vars are the values of the variables.
+	Vars map[string]string
+	// Env looks up variables without a value in Vars in the
+	// environment.
+	// Default: false
+	Env bool
+	// Strict fails on placeholders of variables without a value.
+	// Otherwise they are left as is and reported as problems, which
+	// are only errors with Single.Strict.
+	// Default: false
+	Strict bool
+}
+
+// enabledFor reports whether variables are substituted in block.
+func (t Template) enabledFor(block Block) bool {
+	if value, ok := block.Attributes["template"]; ok {
+		return value != "false"
+	}
+
+	return t.Enabled || slices.Contains(block.Tags, "template")
+}
+
+func (t Template) lookup(name string) (string, bool) {
+	if value, ok := t.Vars[name]; ok {
+		return value, true
+	}
+
+	if t.Env {
+		return os.LookupEnv(name)
+	}
+
+	return "", false
+}
+
+// substitute returns the content of block with the placeholders
+// replaced.
+func (t Template) substitute(block Block) (string, error) {
+	if t.Strict {
+		if unknown := t.unknownVariables(block); len(unknown) > 0 {
+			return "", unknown[0]
+		}
+	}
+
+	return variablePattern.ReplaceAllStringFunc(block.Content, func(placeholder string) string {
+		if strings.HasPrefix(placeholder, "$$") {
+			return placeholder[1:]
+		}
+
+		if value, ok := t.lookup(placeholderName(placeholder)); ok {
+			return value
+		}
+
+		return placeholder
+	}), nil
+}
+
+// placeholderName returns the name of the variable of a placeholder.
+func placeholderName(placeholder string) string {
+	m := variablePattern.FindStringSubmatch(placeholder)
+	return m[1] + m[2]
+}
+
+// unknownVariables returns an error for each placeholder in block of
+// a variable without a value.
+func (t Template) unknownVariables(block Block) []error {
+	ret := []error{}
+	line := block.contentLine()
+
+	for text := range strings.Lines(block.Content) {
+		for _, placeholder := range variablePattern.FindAllString(text, -1) {
+			if strings.HasPrefix(placeholder, "$$") {
+				continue
+			}
+
+			name := placeholderName(placeholder)
+			if _, ok := t.lookup(name); !ok {
+				ret = append(ret, &Error{Source: block.Source, Line: line, Err: fmt.Errorf("%w %q", ErrUnknownVariable, name)})
+			}
+		}
+
+		line++
+	}
+
+	return ret
+}
+
+// substituter returns a function that substitutes variables in code
+// blocks with templating enabled, see Template. The placeholders of
+// unknown variables are added to problems once, even if a code block
+// is substituted multiple times.
+func (t Template) substituter(problems *[]error) func(Block) (string, error) {
+	seen := map[string]bool{}
+
+	return func(block Block) (string, error) {
+		if !t.enabledFor(block) {
+			return block.Content, nil
+		}
+
+		content, err := t.substitute(block)
+		if err != nil {
+			return "", err
+		}
+
+		for _, problem := range t.unknownVariables(block) {
+			if !seen[problem.Error()] {
+				seen[problem.Error()] = true
+				*problems = append(*problems, problem)
+			}
+		}
+
+		return content, nil
+	}
+}
+
+// ReadVarsFile reads variables from a file with one NAME=value pair per
+// line. Empty lines and lines starting with "#" are ignored, values may
+// be quoted and a leading "export " is stripped.
+func ReadVarsFile(path string) (map[string]string, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	ret := map[string]string{}
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		name = strings.TrimSpace(name)
+
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, lineNo)
+		}
+
+		ret[name] = unquote(strings.TrimSpace(value))
+	}
+
+	return ret, scanner.Err()
+}
@@ -0,0 +1,170 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Substitute(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		template Template
+		content  string
+		expected string
+		err      error
+	}{
+		"dollar": {
+			template: Template{Vars: map[string]string{"VERSION": "1.2.3"}},
+			content:  "curl example.com/v${VERSION}.tar.gz\n",
+			expected: "curl example.com/v1.2.3.tar.gz\n",
+		},
+		"go template": {
+			template: Template{Vars: map[string]string{"Namespace": "ci"}},
+			content:  "kubectl -n {{ .Namespace }} get pods\nkubectl -n {{.Namespace}} logs\n",
+			expected: "kubectl -n ci get pods\nkubectl -n ci logs\n",
+		},
+		"escaped": {
+			template: Template{Vars: map[string]string{"HOME": "/root"}},
+			content:  "echo $${HOME} ${HOME}\n",
+			expected: "echo ${HOME} /root\n",
+		},
+		"unknown is kept": {
+			content:  "echo ${HOME} $HOME {{ .X }}\n",
+			expected: "echo ${HOME} $HOME {{ .X }}\n",
+		},
+		"unknown strict": {
+			template: Template{Strict: true},
+			content:  "echo\necho ${MISSING}\n",
+			err:      ErrUnknownVariable,
+		},
+		"vars take precedence over env": {
+			template: Template{Env: true, Vars: map[string]string{"PATH": "vars"}},
+			content:  "${PATH}\n",
+			expected: "vars\n",
+		},
+	}
+
+	for title, tc := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			content, err := tc.template.substitute(Block{Content: tc.content, Source: "doc.md", StartLine: 1, FenceChar: '`'})
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				require.EqualError(t, err, `doc.md:3: unknown variable "MISSING"`)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, content)
+		})
+	}
+}
+
+func TestTemplate_Substitute_Env(t *testing.T) {
+	t.Setenv("MDEXTRACT_TEST_VAR", "from env")
+
+	content, err := Template{Env: true}.substitute(Block{Content: "${MDEXTRACT_TEST_VAR}\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "from env\n", content)
+
+	content, err = Template{}.substitute(Block{Content: "${MDEXTRACT_TEST_VAR}\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "${MDEXTRACT_TEST_VAR}\n", content)
+}
+
+func TestSingle_Extract_Template(t *testing.T) {
+	t.Parallel()
+
+	input := "```sh\necho ${NAME}\n```\n\n```sh template\necho ${NAME}\n```\n\n```sh template=false\necho ${NAME}\n```\n"
+	vars := map[string]string{"NAME": "ci"}
+
+	extracted, err := Single{Template: Template{Vars: vars}}.Extract([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, "echo ${NAME}\necho ci\necho ${NAME}\n", extracted)
+
+	extracted, err = Single{Template: Template{Vars: vars, Enabled: true}}.Extract([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, "echo ci\necho ci\necho ${NAME}\n", extracted)
+}
+
+func TestSingle_Extract_TemplateReferences(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"```sh ci",
+		"<<setup>>",
+		"echo ${NAME}",
+		"```",
+		"",
+		"```sh name=setup template",
+		"export NAME=${NAME}",
+		"echo ${MISSING}",
+		"```",
+	}, "\n")
+
+	warnings := &strings.Builder{}
+	single := Single{
+		Tags:             []string{"ci"},
+		ExpandReferences: true,
+		Template:         Template{Vars: map[string]string{"NAME": "ci"}},
+		SourceName:       "doc.md",
+		Warnings:         warnings,
+	}
+
+	// only the referenced code block has the template tag
+	extracted, err := single.Extract([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, "export NAME=ci\necho ${MISSING}\necho ${NAME}\n", extracted)
+	assert.Equal(t, "warning: doc.md:8: unknown variable \"MISSING\"\n", warnings.String())
+}
+
+func TestReadVarsFile(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "vars.env")
+	content := "# comment\nVERSION=1.2.3\n\nexport NAMESPACE = ci\nTITLE=\"hello world\"\n"
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+
+	vars, err := ReadVarsFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"VERSION": "1.2.3", "NAMESPACE": "ci", "TITLE": "hello world"}, vars)
+
+	require.NoError(t, os.WriteFile(p, []byte("VERSION=1\ninvalid\n"), 0o600))
+
+	_, err = ReadVarsFile(p)
+	require.EqualError(t, err, p+":2: expected NAME=value")
+}
+
+func TestSingle_FlagSet_Vars(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "vars.env")
+	require.NoError(t, os.WriteFile(p, []byte("A=file\nB=file\n"), 0o600))
+
+	single := &Single{}
+	require.NoError(t, single.FlagSet().Parse([]string{"-var", "A=flag", "-vars-file", p, "-var", "C=x=y"}))
+	assert.Equal(t, map[string]string{"A": "flag", "B": "file", "C": "x=y"}, single.Template.Vars)
+}
+
+func TestSingle_Extract_TemplateStrictPosition(t *testing.T) {
+	t.Parallel()
+
+	single := Single{Template: Template{Enabled: true, Strict: true}, SourceName: "doc.md"}
+
+	_, err := single.Extract([]byte(strings.Join([]string{
+		"# Title",
+		"",
+		"```sh",
+		"echo ${MISSING}",
+		"```",
+	}, "\n")))
+	require.EqualError(t, err, `doc.md:4: unknown variable "MISSING"`)
+}
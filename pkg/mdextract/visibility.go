@@ -0,0 +1,90 @@
+package mdextract
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Visibility describes whether a code block is shown to readers of the
+// rendered markdown.
+type Visibility int
+
+const (
+	// VisibilityVisible code blocks are rendered.
+	VisibilityVisible Visibility = iota
+	// VisibilityComment code blocks are inside HTML comments and not
+	// rendered.
+	VisibilityComment
+	// VisibilityDetails code blocks are inside <details> elements and
+	// only shown when expanded.
+	VisibilityDetails
+)
+
+// ErrUnknownVisibility is returned for unknown visibilities.
+var ErrUnknownVisibility = errors.New("unknown visibility")
+
+var visibilityNames = map[Visibility]string{
+	VisibilityVisible: "visible",
+	VisibilityComment: "comment",
+	VisibilityDetails: "details",
+}
+
+// DefaultVisibility are the visibilities of code blocks that are
+// extracted if none are configured.
+var DefaultVisibility = []Visibility{VisibilityVisible, VisibilityComment}
+
+func (v Visibility) String() string {
+	if name, ok := visibilityNames[v]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Visibility(%d)", int(v))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v Visibility) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// ParseVisibility parses a comma-separated list of visibilities like
+// "visible,comment,details". "all" is every visibility.
+func ParseVisibility(s string) ([]Visibility, error) {
+	ret := []Visibility{}
+
+	for _, name := range split(s) {
+		name = strings.TrimSpace(name)
+
+		if name == "all" {
+			return []Visibility{VisibilityVisible, VisibilityComment, VisibilityDetails}, nil
+		}
+
+		found := false
+
+		for v, vName := range visibilityNames {
+			if name == vName {
+				found = true
+
+				if !slices.Contains(ret, v) {
+					ret = append(ret, v)
+				}
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w %q", ErrUnknownVisibility, name)
+		}
+	}
+
+	return ret, nil
+}
+
+// visibility returns the visibilities of the code blocks to extract.
+func (single Single) visibility() []Visibility {
+	if single.Visibility == nil {
+		return DefaultVisibility
+	}
+
+	return single.Visibility
+}
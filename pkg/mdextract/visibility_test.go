@@ -0,0 +1,63 @@
+package mdextract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVisibility(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string][]Visibility{
+		"":                         {},
+		"visible":                  {VisibilityVisible},
+		"comment,details":          {VisibilityComment, VisibilityDetails},
+		"details, visible,details": {VisibilityDetails, VisibilityVisible},
+		"all":                      {VisibilityVisible, VisibilityComment, VisibilityDetails},
+	} {
+		visibility, err := ParseVisibility(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, visibility, input)
+	}
+
+	_, err := ParseVisibility("visible,hidden")
+	require.ErrorIs(t, err, ErrUnknownVisibility)
+}
+
+func TestSingle_FlagSet_Visibility(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		args     []string
+		expected []Visibility
+	}{
+		"default": {
+			args:     []string{},
+			expected: DefaultVisibility,
+		},
+		"exclude comments": {
+			args:     []string{"-exclude-comments"},
+			expected: []Visibility{VisibilityVisible},
+		},
+		"exclude comments false": {
+			args:     []string{"-exclude-comments=false"},
+			expected: DefaultVisibility,
+		},
+		"visibility": {
+			args:     []string{"-visibility", "all", "-exclude-comments"},
+			expected: []Visibility{VisibilityVisible, VisibilityDetails},
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			single := Single{}
+			require.NoError(t, single.FlagSet().Parse(cas.args))
+			assert.Equal(t, cas.expected, single.visibility())
+		})
+	}
+}
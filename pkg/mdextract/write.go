@@ -0,0 +1,220 @@
+package mdextract
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const defaultDirMode = 0o750
+
+var (
+	// ErrAbsolutePath is returned for "file" attributes with an
+	// absolute path.
+	ErrAbsolutePath = errors.New("absolute paths are not allowed")
+	// ErrPathEscapes is returned for "file" attributes pointing
+	// outside of the output directory.
+	ErrPathEscapes = errors.New("path escapes the output directory")
+)
+
+func (multi Multi) outputDir() string {
+	if multi.OutputDir == "" {
+		return "."
+	}
+
+	return multi.OutputDir
+}
+
+// checkPath validates that name is a relative path that stays inside
+// the output directory.
+func checkPath(name string) error {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || (name != "" && (name[0] == '/' || name[0] == '\\')) {
+		return fmt.Errorf("file %q: %w", name, ErrAbsolutePath)
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("file %q: %w", name, ErrPathEscapes)
+	}
+
+	return nil
+}
+
+// openRoot creates and opens the output directory. All files are
+// written through the returned root, which rejects paths and symlinks
+// pointing outside of it.
+func (multi Multi) openRoot() (*os.Root, error) {
+	if err := os.MkdirAll(multi.outputDir(), defaultDirMode); err != nil {
+		return nil, err
+	}
+
+	return os.OpenRoot(multi.outputDir())
+}
+
+// Write writes the contents to files inside OutputDir with FileMode,
+// see WriteModes.
+func (multi Multi) Write(contents map[string]string) error {
+	return multi.WriteModes(contents, nil)
+}
+
+// WriteModes writes the contents to files inside OutputDir. Files are
+// written with the mode in modes, or FileMode if they have none, see
+// Modes. If neither is set existing files keep their mode and new files
+// are created with 0600. Intermediate directories are created as
+// needed.
+// Absolute paths, paths containing ".." that escape OutputDir and
+// paths through symlinks pointing outside of OutputDir are rejected.
+//
+// Each file is replaced atomically by writing a temporary file in the
+// same directory and renaming it. If Append is set the contents are
+// appended to the files instead.
+func (multi Multi) WriteModes(contents map[string]string, modes map[string]os.FileMode) error {
+	for _, name := range slices.Sorted(maps.Keys(contents)) {
+		if err := checkPath(name); err != nil {
+			return err
+		}
+	}
+
+	root, err := multi.openRoot()
+	if err != nil {
+		return err
+	}
+	defer root.Close() //nolint:errcheck
+
+	for _, name := range slices.Sorted(maps.Keys(contents)) {
+		data := []byte(contents[name])
+
+		mode, ok := modes[name]
+		if !ok {
+			mode = os.FileMode(multi.FileMode)
+		}
+
+		name = filepath.FromSlash(name)
+
+		if err := mkdirParents(root, name); err != nil {
+			return err
+		}
+
+		if mode == 0 {
+			mode = existingMode(root, name)
+		}
+
+		if multi.Append {
+			err = appendFile(root, name, data, mode)
+		} else {
+			err = writeAtomic(root, name, data, mode)
+		}
+
+		if err != nil {
+			return fmt.Errorf("file %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteFile writes data to the file at path. The file is replaced
+// atomically by writing a temporary file in the same directory and
+// renaming it. If appendData is true data is appended to the file
+// instead. If perm is 0 an existing file keeps its mode and a new file
+// is created with 0600.
+func WriteFile(path string, data []byte, perm os.FileMode, appendData bool) error {
+	root, err := os.OpenRoot(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer root.Close() //nolint:errcheck
+
+	if perm == 0 {
+		perm = existingMode(root, filepath.Base(path))
+	}
+
+	if appendData {
+		return appendFile(root, filepath.Base(path), data, perm)
+	}
+
+	return writeAtomic(root, filepath.Base(path), data, perm)
+}
+
+// existingMode returns the permissions of the file with the given name
+// in root, or defaultFileMode if it does not exist.
+func existingMode(root *os.Root, name string) os.FileMode {
+	info, err := root.Lstat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return defaultFileMode
+	}
+
+	return info.Mode().Perm()
+}
+
+func mkdirParents(root *os.Root, name string) error {
+	dir := filepath.Dir(name)
+	if dir == "." {
+		return nil
+	}
+
+	if err := root.MkdirAll(dir, defaultDirMode); err != nil {
+		return fmt.Errorf("file %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// appendFile appends data to the file with the given name in root.
+func appendFile(root *os.Root, name string, data []byte, perm os.FileMode) error {
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+// writeAtomic replaces the file with the given name in root by writing
+// data to a temporary file in the same directory and renaming it.
+func writeAtomic(root *os.Root, name string, data []byte, perm os.FileMode) error {
+	suffix := make([]byte, 8) //nolint:mnd
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".tmp-"+hex.EncodeToString(suffix))
+
+	f, err := root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	// the mode is set explicitly as it is not affected by the umask
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+
+	if err == nil {
+		err = root.Rename(tmp, name)
+	}
+
+	if err != nil {
+		_ = root.Remove(tmp)
+	}
+
+	return err
+}
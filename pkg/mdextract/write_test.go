@@ -0,0 +1,241 @@
+package mdextract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		name     string
+		expected error
+	}{
+		"file":             {"main.go", nil},
+		"nested":           {"sub/dir/main.go", nil},
+		"dot":              {"./main.go", nil},
+		"inner dots":       {"sub/../main.go", nil},
+		"absolute":         {"/etc/passwd", ErrAbsolutePath},
+		"escape":           {"../main.go", ErrPathEscapes},
+		"nested escape":    {"../../etc/x", ErrPathEscapes},
+		"escape via inner": {"sub/../../main.go", ErrPathEscapes},
+		"empty":            {"", ErrPathEscapes},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkPath(cas.name)
+			if cas.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, cas.expected)
+		})
+	}
+}
+
+func TestMulti_ExtractFromFileAndWrite_OutputDir(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "out")
+
+	mdFile := filepath.Join(tmpDir, "test.md")
+	require.NoError(t, os.WriteFile(mdFile, []byte("```go file=sub/dir/main.go\npackage main\n```\n"), 0o600))
+
+	multi := &Multi{OutputDir: outputDir}
+	require.NoError(t, multi.ExtractFromFileAndWrite(mdFile))
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "sub", "dir", "main.go")) //nolint:gosec
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+}
+
+func TestMulti_ExtractFromFileAndWrite_Confinement(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"absolute":        "/tmp/x.go",
+		"escape":          "../x.go",
+		"symlinked dir":   "link/x.go",
+		"symlink in path": "link/sub/x.go",
+	}
+
+	for title, file := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			outside := filepath.Join(tmpDir, "outside")
+			outputDir := filepath.Join(tmpDir, "out")
+
+			require.NoError(t, os.MkdirAll(outside, 0o700))
+			require.NoError(t, os.MkdirAll(outputDir, 0o700))
+			require.NoError(t, os.Symlink(outside, filepath.Join(outputDir, "link")))
+
+			mdFile := filepath.Join(tmpDir, "test.md")
+			require.NoError(t, os.WriteFile(mdFile, []byte("```go file="+file+"\npackage main\n```\n"), 0o600))
+
+			multi := &Multi{OutputDir: outputDir}
+			require.Error(t, multi.ExtractFromFileAndWrite(mdFile))
+
+			entries, err := os.ReadDir(outside)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestMulti_Write_ReplacesSymlink(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	outside := filepath.Join(tmpDir, "outside")
+	outputDir := filepath.Join(tmpDir, "out")
+
+	require.NoError(t, os.MkdirAll(outside, 0o700))
+	require.NoError(t, os.MkdirAll(outputDir, 0o700))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "target"), filepath.Join(outputDir, "link")))
+
+	multi := &Multi{OutputDir: outputDir}
+	require.NoError(t, multi.Write(map[string]string{"link": "content"}))
+
+	// the symlink is replaced instead of written through
+	info, err := os.Lstat(filepath.Join(outputDir, "link"))
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+
+	entries, err := os.ReadDir(outside)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMulti_Write(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+	multi := &Multi{OutputDir: outputDir}
+
+	require.NoError(t, multi.Write(map[string]string{"a.txt": "first run\n"}))
+	require.NoError(t, multi.Write(map[string]string{"a.txt": "second\n"}))
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "a.txt")) //nolint:gosec
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(content))
+
+	multi.Append = true
+	require.NoError(t, multi.Write(map[string]string{"a.txt": "appended\n"}))
+
+	content, err = os.ReadFile(filepath.Join(outputDir, "a.txt")) //nolint:gosec
+	require.NoError(t, err)
+	assert.Equal(t, "second\nappended\n", string(content))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	info, err := os.Stat(filepath.Join(outputDir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(defaultFileMode), info.Mode().Perm())
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "out.sh")
+
+	require.NoError(t, WriteFile(p, []byte("a long first run\n"), 0o600, false))
+	require.NoError(t, WriteFile(p, []byte("short\n"), 0o600, false))
+
+	content, err := os.ReadFile(p) //nolint:gosec
+	require.NoError(t, err)
+	assert.Equal(t, "short\n", string(content))
+
+	require.NoError(t, WriteFile(p, []byte("more\n"), 0o600, true))
+
+	content, err = os.ReadFile(p) //nolint:gosec
+	require.NoError(t, err)
+	assert.Equal(t, "short\nmore\n", string(content))
+}
+
+func TestMulti_ExtractFromFileAndWrite_KeepsMode(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		multi    Multi
+		info     string
+		expected os.FileMode
+	}{
+		"existing mode": {
+			expected: 0o755,
+		},
+		"file mode": {
+			multi:    Multi{FileMode: 0o640},
+			expected: 0o640,
+		},
+		"mode attribute": {
+			info:     " mode=0700",
+			expected: 0o700,
+		},
+		"executable": {
+			multi:    Multi{Executable: true},
+			expected: 0o700,
+		},
+	}
+
+	for title, cas := range cases {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			outputFile := filepath.Join(tmpDir, "out.sh")
+
+			mdFile := filepath.Join(tmpDir, "test.md")
+			require.NoError(t, os.WriteFile(mdFile, []byte("```sh file=out.sh"+cas.info+"\n#!/bin/sh\n```\n"), 0o600))
+
+			multi := cas.multi
+			multi.OutputDir = tmpDir
+			require.NoError(t, multi.ExtractFromFileAndWrite(mdFile))
+			require.NoError(t, os.Chmod(outputFile, 0o755)) //nolint:gosec
+			require.NoError(t, multi.ExtractFromFileAndWrite(mdFile))
+
+			info, err := os.Stat(outputFile)
+			require.NoError(t, err)
+			assert.Equal(t, cas.expected, info.Mode().Perm())
+		})
+	}
+}
+
+func TestWriteFile_KeepsMode(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "out.sh")
+
+	require.NoError(t, WriteFile(p, []byte("first\n"), 0, false))
+
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(defaultFileMode), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(p, 0o755)) //nolint:gosec
+	require.NoError(t, WriteFile(p, []byte("second\n"), 0, false))
+
+	info, err = os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	require.NoError(t, WriteFile(p, []byte("third\n"), 0o600, false))
+
+	info, err = os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
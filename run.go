@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ntnn/mdextract/pkg/mdextract"
+)
+
+// runBlocks executes the code blocks in the given markdown files and
+// reports the result of each.
+func runBlocks(args []string) error {
+	single := &mdextract.Single{}
+	fs := single.ProcessFlagSet()
+	fs.Init("run", fs.ErrorHandling())
+
+	runner := mdextract.Runner{}
+
+	fs.BoolVar(&runner.Session, "session", false,
+		"Run the shell code blocks of each language in one persistent shell session instead of isolated")
+	fs.DurationVar(&runner.Timeout, "timeout", 0, "Timeout for each code block, e.g. 30s (0 for no timeout)")
+	fs.StringVar(&runner.Dir, "dir", "", "Working directory for the interpreters")
+
+	fs.Func("interpreter", "Interpreter for a language as lang=command, "+
+		"e.g. 'python=python3 -u', can be repeated", func(s string) error {
+		lang, command, ok := strings.Cut(s, "=")
+		if !ok || lang == "" || len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("invalid interpreter %q, expected lang=command", s)
+		}
+
+		if runner.Interpreters == nil {
+			runner.Interpreters = map[string][]string{}
+		}
+
+		runner.Interpreters[lang] = strings.Fields(command)
+
+		return nil
+	})
+
+	fs.Func("match", "Default match mode for expected outputs: exact, ellipsis or regex", func(s string) error {
+		mode, err := mdextract.ParseMatchMode(s)
+		runner.Match = mode
+
+		return err
+	})
+	fs.BoolVar(&runner.Trim, "trim", false,
+		"Ignore leading and trailing blank lines and trailing whitespace when comparing expected outputs")
+
+	fUpdate := fs.Bool("update", false,
+		"Rewrite mismatching expected outputs in the markdown files with the actual outputs")
+	fVerbose := fs.Bool("v", false, "Print the output of passing code blocks")
+	excludePaths := excludePathFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.PrintDefaults()
+		return errors.New("no input files specified")
+	}
+
+	inputs, err := mdextract.FindInputs(fs.Args(), *excludePaths)
+	if err != nil {
+		return err
+	}
+
+	all := []mdextract.Block{}
+
+	for _, input := range inputs {
+		inputBlocks, err := readBlocks(single, input)
+		if err != nil {
+			return err
+		}
+
+		all = append(all, inputBlocks...)
+	}
+
+	blocks, err := single.SelectWithOutputs(all)
+	if err != nil {
+		return err
+	}
+
+	if !*fUpdate {
+		return reportResults(os.Stdout, runner.Run(context.Background(), blocks), *fVerbose, false)
+	}
+
+	results := slices.Collect(runner.Run(context.Background(), blocks))
+	reportErr := reportResults(os.Stdout, slices.Values(results), *fVerbose, true)
+
+	for _, input := range inputs {
+		if err := updateExpected(input, results); err != nil {
+			return err
+		}
+	}
+
+	return reportErr
+}
+
+// updateExpected rewrites the expected outputs in the markdown file
+// with the actual outputs.
+func updateExpected(input string, results []mdextract.Result) error {
+	if input == "-" {
+		return errors.New("-update cannot be used with stdin")
+	}
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(input) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	updated, err := mdextract.UpdateExpected(input, current, results)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(current, updated) {
+		return nil
+	}
+
+	return mdextract.WriteFile(input, updated, info.Mode().Perm(), false)
+}
+
+// reportResults prints a line per result and the output of failed code
+// blocks. It returns an error if any code block failed. If update is
+// true mismatching outputs are reported as updated instead of failed.
+func reportResults(w io.Writer, results iter.Seq[mdextract.Result], verbose, update bool) error {
+	passed, failed, skipped, updated := 0, 0, 0, 0
+
+	for result := range results {
+		location := fmt.Sprintf("%s:%d", result.Block.Source, result.Block.StartLine)
+		duration := result.Duration.Round(time.Millisecond)
+
+		switch {
+		case result.Skipped:
+			skipped++
+
+			fmt.Fprintf(w, "SKIP %s %s: %v\n", location, result.Block.Language(), result.Err)
+		case update && errors.Is(result.Err, mdextract.ErrOutputMismatch):
+			updated++
+
+			fmt.Fprintf(w, "UPDATE %s %s (%s)\n", location, result.Block.Language(), duration)
+		case result.Err != nil:
+			failed++
+
+			// mismatching outputs carry a diff, which replaces the
+			// output
+			msg, diff, ok := strings.Cut(result.Err.Error(), "\n")
+
+			fmt.Fprintf(w, "FAIL %s %s (%s): %s\n", location, result.Block.Language(), duration, msg)
+
+			if ok {
+				writeIndented(w, diff)
+			} else {
+				writeIndented(w, result.Output)
+			}
+		default:
+			passed++
+
+			fmt.Fprintf(w, "PASS %s %s (%s)\n", location, result.Block.Language(), duration)
+
+			if verbose {
+				writeIndented(w, result.Output)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%d passed, %d failed, %d skipped", passed, failed, skipped)
+
+	if update {
+		fmt.Fprintf(w, ", %d updated", updated)
+	}
+
+	fmt.Fprintln(w)
+
+	if failed > 0 {
+		return fmt.Errorf("%d code block(s) failed", failed)
+	}
+
+	return nil
+}
+
+func writeIndented(w io.Writer, output string) {
+	for line := range strings.Lines(output) {
+		fmt.Fprintf(w, "    %s", line)
+	}
+
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		fmt.Fprintln(w)
+	}
+}